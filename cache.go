@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// apiCache holds Management API reads for the lifetime of the provider
+// process, so resources and data sources share them instead of repeating
+// them. Concurrent misses for the same key share a single load. A nil
+// *apiCache caches nothing.
+type apiCache struct {
+	// ttl is how long entries stay valid; zero keeps them for the whole run.
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*apiCacheEntry
+}
+
+type apiCacheEntry struct {
+	ready   chan struct{}
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+func newAPICache(ttl time.Duration) *apiCache {
+	return &apiCache{
+		ttl:     ttl,
+		entries: make(map[string]*apiCacheEntry),
+	}
+}
+
+// get returns the cached value of key, calling load to fill it on a miss.
+// Failed loads aren't cached. get returns ctx.Err() as soon as ctx is done,
+// even while the load it started keeps running for other callers.
+func (c *apiCache) get(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return load(ctx)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && entry.isExpired() {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		entry = &apiCacheEntry{ready: make(chan struct{})}
+		c.entries[key] = entry
+
+		// Other callers may be waiting for this load, so it runs detached
+		// and isn't aborted when the caller that started it gives up; that
+		// caller waits for it like every other one.
+		go c.load(context.WithoutCancel(ctx), key, entry, load)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.value, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load fills entry by calling load, dropping it again when the load fails.
+func (c *apiCache) load(ctx context.Context, key string, entry *apiCacheEntry, load func(ctx context.Context) (interface{}, error)) {
+	entry.value, entry.err = load(ctx)
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	if entry.err != nil {
+		c.remove(key, entry)
+	}
+	close(entry.ready)
+}
+
+// set stores value under key, e.g. after a write made it known.
+func (c *apiCache) set(key string, value interface{}) {
+	if c == nil {
+		return
+	}
+
+	entry := &apiCacheEntry{
+		ready: make(chan struct{}),
+		value: value,
+	}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	close(entry.ready)
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+}
+
+// invalidate drops key, so the next get loads it again.
+func (c *apiCache) invalidate(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// remove drops key only if it still holds entry.
+func (c *apiCache) remove(key string, entry *apiCacheEntry) {
+	c.mu.Lock()
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+}
+
+func (e *apiCacheEntry) isExpired() bool {
+	select {
+	case <-e.ready:
+		return !e.expires.IsZero() && time.Now().After(e.expires)
+	default:
+		// Loads in flight are never expired.
+		return false
+	}
+}
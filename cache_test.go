@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"bitbucket.org/cerifi/terraform-provider-auth0-connections/internal/auth0fake"
+)
+
+func TestCacheReturnsOnDeadline(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0", EnabledClients: []string{"app_1"}})
+	server.InjectFault(auth0fake.LatencyFault("/api/v2/connections/con_1/clients", 2*time.Second))
+	client := newTestClient(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.getConnectionClients(ctx, "con_1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %s, want right after the deadline", elapsed)
+	}
+}
+
+func TestCacheLoadOutlivesCancelledCaller(t *testing.T) {
+	cache := newAPICache(0)
+	release := make(chan struct{})
+	loads := 0
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		<-release
+		return "value", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.get(ctx, "key", load); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want the cancellation", err)
+	}
+
+	close(release)
+	value, err := cache.get(context.Background(), "key", load)
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if value != "value" || loads != 1 {
+		t.Errorf("got %v after %d loads, want the first load's value", value, loads)
+	}
+}
+
+func TestCacheEntriesExpire(t *testing.T) {
+	cache := newAPICache(50 * time.Millisecond)
+	loads := 0
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get(context.Background(), "key", load); err != nil {
+			t.Fatalf("get: %s", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("got %d loads before expiry, want 1", loads)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	value, err := cache.get(context.Background(), "key", load)
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if value != 2 {
+		t.Errorf("got %v after expiry, want a new load", value)
+	}
+}
+
+func TestCacheFailedLoadsAreNotCached(t *testing.T) {
+	cache := newAPICache(0)
+	loads := 0
+	load := func(ctx context.Context) (interface{}, error) {
+		loads++
+		if loads == 1 {
+			return nil, errors.New("failed")
+		}
+		return "value", nil
+	}
+
+	if _, err := cache.get(context.Background(), "key", load); err == nil {
+		t.Fatalf("got no error from the failing load")
+	}
+	if value, err := cache.get(context.Background(), "key", load); err != nil || value != "value" {
+		t.Errorf("got %v, %v, want a new load", value, err)
+	}
+}
+
+func TestCacheWriteThrough(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0", EnabledClients: []string{"other_app"}})
+	client := newTestClient(t, server)
+
+	if _, err := client.getConnectionClients(context.Background(), "con_1"); err != nil {
+		t.Fatalf("getConnectionClients: %s", err)
+	}
+	if _, err := client.setConnectionClientEnabled(context.Background(), "con_1", "app_1", true); err != nil {
+		t.Fatalf("setConnectionClientEnabled: %s", err)
+	}
+	reads := server.RequestCount(http.MethodGet, "/api/v2/connections/con_1/clients")
+
+	got, err := client.getConnectionClients(context.Background(), "con_1")
+	if err != nil {
+		t.Fatalf("getConnectionClients: %s", err)
+	}
+	if !stringSlicesEqual(sortedCopy(got), []string{"app_1", "other_app"}) {
+		t.Errorf("got clients %v after the write, want the written ones", got)
+	}
+	if after := server.RequestCount(http.MethodGet, "/api/v2/connections/con_1/clients"); after != reads {
+		t.Errorf("got %d reads after the write, want the cached result", after-reads)
+	}
+
+	// A change made outside the provider is only seen once the entry is
+	// invalidated.
+	server.SetConnectionClient("con_1", "app_2", true)
+	client.cache.invalidate(connectionClientsCacheKey("con_1"))
+
+	got, err = client.getConnectionClients(context.Background(), "con_1")
+	if err != nil {
+		t.Fatalf("getConnectionClients: %s", err)
+	}
+	if !containsString(got, "app_2") {
+		t.Errorf("got clients %v after invalidation, want app_2 read again", got)
+	}
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenRefreshLeeway is how long before its reported expiry a cached access
+// token is considered stale and gets refreshed.
+const tokenRefreshLeeway = 5 * time.Minute
+
+// connectionsPageSize is the number of connections requested per page, which
+// is the maximum the Management API allows.
+const connectionsPageSize = 100
+
+// connectionClientsPageSize is the number of clients requested per page of
+// a connection's enabled clients.
+const connectionClientsPageSize = 100
+
+// connectionClientsUpdateLimit is the maximum number of client status changes
+// the Management API accepts in one PATCH of /connections/{id}/clients.
+const connectionClientsUpdateLimit = 50
+
+// connectionsCacheKey is the cache key of a connection listing.
+func connectionsCacheKey(opts connectionListOptions) string {
+	return "connections?" + opts.query().Encode()
+}
+
+// connectionClientsCacheKey is the cache key of a connection's clients.
+func connectionClientsCacheKey(connectionId string) string {
+	return "connections/" + connectionId + "/clients"
+}
+
+// Auth0 API response structure
+type Auth0ConnectionsResponse struct {
+	Connections []Auth0Connection `json:"connections"`
+	Total       int               `json:"total"`
+	Start       int               `json:"start"`
+	Limit       int               `json:"limit"`
+	Length      int               `json:"length"`
+}
+
+// Auth0ConnectionClientsResponse is a page of /connections/{id}/clients.
+type Auth0ConnectionClientsResponse struct {
+	Clients []struct {
+		ClientId string `json:"client_id"`
+	} `json:"clients"`
+	Next string `json:"next"`
+}
+
+// Auth0ConnectionClientStatus enables or disables one client on a connection.
+type Auth0ConnectionClientStatus struct {
+	ClientId string `json:"client_id"`
+	Status   bool   `json:"status"`
+}
+
+type Auth0Connection struct {
+	Id             string   `json:"id"`
+	Name           string   `json:"name"`
+	Strategy       string   `json:"strategy"`
+	DisplayName    string   `json:"display_name"`
+	Enabled        bool     `json:"enabled"`
+	EnabledClients []string `json:"enabled_clients,omitempty"`
+}
+
+// getAccessToken returns a Management API access token, requesting a new one
+// through the client credentials grant only when the cached token is missing
+// or about to expire. Concurrent callers share a single token request. A
+// static api_token is returned as is for as long as it has not expired.
+func (c *Auth0Client) getAccessToken(ctx context.Context) (string, error) {
+	if c.APIToken != "" {
+		if !c.APITokenExpiry.IsZero() && !time.Now().Before(c.APITokenExpiry) {
+			return "", fmt.Errorf("the configured api_token expired at %s; request a fresh Management API token and run again", c.APITokenExpiry.Format(time.RFC3339))
+		}
+		return c.APIToken, nil
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.tokenExpiry) {
+		return c.accessToken, nil
+	}
+
+	// A client assertion may only be used once, so every attempt the retry
+	// transport makes gets a freshly signed one. Its length doesn't change
+	// between signatures, so the content length stays valid.
+	newBody := func() (io.ReadCloser, error) {
+		body, err := c.tokenRequestBody()
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+
+	body, err := c.tokenRequestBody()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.GetBody = newBody
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", newAuth0APIError(req.Method, c.TokenURL, resp.StatusCode, body)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	// Refresh ahead of the real expiry, but never use more than half of the
+	// token lifetime as leeway so short-lived tokens are still reused.
+	lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+	leeway := tokenRefreshLeeway
+	if leeway > lifetime/2 {
+		leeway = lifetime / 2
+	}
+
+	c.accessToken = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(lifetime - leeway)
+
+	return c.accessToken, nil
+}
+
+// tokenRequestBody returns the form of a client credentials grant, signing a
+// new client assertion when Private Key JWT authentication is configured.
+func (c *Auth0Client) tokenRequestBody() (string, error) {
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", c.ClientId)
+	if c.ClientAssertionKey != nil {
+		assertion, err := signClientAssertion(c.ClientAssertionKey, c.ClientAssertionSigningAlg, c.ClientAssertionKeyId, c.ClientId, clientAssertionAudience(c.TokenURL), time.Now())
+		if err != nil {
+			return "", err
+		}
+		data.Set("client_assertion_type", clientAssertionType)
+		data.Set("client_assertion", assertion)
+	} else {
+		data.Set("client_secret", c.ClientSecret)
+	}
+	data.Set("audience", c.Audience)
+
+	return data.Encode(), nil
+}
+
+// openIDConfiguration is the part of an Auth0 host's OpenID configuration
+// used to verify custom domains.
+type openIDConfiguration struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// getOpenIDConfiguration returns the OpenID configuration of the host serving
+// baseURL, e.g. the Management API or the token endpoint.
+func (c *Auth0Client) getOpenIDConfiguration(ctx context.Context, baseURL string) (*openIDConfiguration, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", baseURL, err)
+	}
+	discoveryURL := fmt.Sprintf("%s://%s/.well-known/openid-configuration", parsed.Scheme, parsed.Host)
+
+	var configuration openIDConfiguration
+	if err := c.getPublicJSON(ctx, discoveryURL, &configuration); err != nil {
+		return nil, fmt.Errorf("failed to read OpenID configuration: %w", err)
+	}
+
+	if configuration.Issuer == "" {
+		return nil, fmt.Errorf("OpenID configuration of %s has no issuer", parsed.Host)
+	}
+	if configuration.JWKSURI == "" {
+		return nil, fmt.Errorf("OpenID configuration of %s has no jwks_uri", parsed.Host)
+	}
+
+	return &configuration, nil
+}
+
+// getSigningKeyIDs returns the IDs of the keys published at jwksURI. Every
+// host of a tenant, including its custom domains, publishes the same keys.
+func (c *Auth0Client) getSigningKeyIDs(ctx context.Context, jwksURI string) ([]string, error) {
+	var jwks struct {
+		Keys []struct {
+			KeyId string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := c.getPublicJSON(ctx, jwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to read signing keys: %w", err)
+	}
+
+	var keyIds []string
+	for _, key := range jwks.Keys {
+		if key.KeyId != "" {
+			keyIds = append(keyIds, key.KeyId)
+		}
+	}
+
+	if len(keyIds) == 0 {
+		return nil, fmt.Errorf("%s has no signing key IDs", jwksURI)
+	}
+
+	return keyIds, nil
+}
+
+// getPublicJSON reads an unauthenticated JSON document, such as an OpenID
+// configuration, into out.
+func (c *Auth0Client) getPublicJSON(ctx context.Context, documentURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", documentURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newAuth0APIError(req.Method, documentURL, resp.StatusCode, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", documentURL, err)
+	}
+
+	return nil
+}
+
+// doRequest performs an authenticated Management API request against the
+// given path (relative to APIBaseURL) and decodes the JSON response into out
+// when out is not nil.
+func (c *Auth0Client) doRequest(ctx context.Context, method string, apiPath string, query url.Values, payload interface{}, out interface{}) error {
+	accessToken, err := c.getAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	requestURL := strings.TrimRight(c.APIBaseURL, "/") + "/" + apiPath
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s payload: %w", apiPath, err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", apiPath, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make %s request: %w", apiPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newAuth0APIError(method, apiPath, resp.StatusCode, respBody)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", apiPath, err)
+	}
+
+	return nil
+}
+
+// connectionListOptions narrows a connection listing on the server side.
+type connectionListOptions struct {
+	// Strategies and Name only return matching connections; empty values
+	// don't filter.
+	Strategies []string
+	Name       string
+
+	// Fields limits the connection fields returned; empty returns them all,
+	// including large options such as SAML certificates and scripts.
+	Fields []string
+}
+
+// query returns the query parameters of the listing, without pagination.
+func (o connectionListOptions) query() url.Values {
+	query := url.Values{}
+
+	for _, strategy := range sortedCopy(o.Strategies) {
+		query.Add("strategy", strategy)
+	}
+	if o.Name != "" {
+		query.Set("name", o.Name)
+	}
+	if len(o.Fields) > 0 {
+		query.Set("fields", strings.Join(sortedCopy(o.Fields), ","))
+		query.Set("include_fields", "true")
+	}
+
+	return query
+}
+
+// listConnections returns the connections of the tenant matching opts, from
+// the cache when the same listing has been read before.
+func (c *Auth0Client) listConnections(ctx context.Context, opts connectionListOptions) ([]Auth0Connection, error) {
+	connections, err := c.cache.get(ctx, connectionsCacheKey(opts), func(ctx context.Context) (interface{}, error) {
+		return c.fetchConnections(ctx, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Auth0Connection{}, connections.([]Auth0Connection)...), nil
+}
+
+// fetchConnections reads the connections of the tenant matching opts,
+// following the page/per_page pagination of the Management API until all
+// pages are read.
+func (c *Auth0Client) fetchConnections(ctx context.Context, opts connectionListOptions) ([]Auth0Connection, error) {
+	var connections []Auth0Connection
+
+	for page := 0; ; page++ {
+		query := opts.query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(connectionsPageSize))
+		query.Set("include_totals", "true")
+
+		var connectionsResp Auth0ConnectionsResponse
+		if err := c.doRequest(ctx, http.MethodGet, "connections", query, nil, &connectionsResp); err != nil {
+			return nil, err
+		}
+
+		connections = append(connections, connectionsResp.Connections...)
+
+		// Stop on a short page as well as on the reported total, so a total
+		// that changes while paging can't make us loop forever.
+		if len(connectionsResp.Connections) < connectionsPageSize || len(connections) >= connectionsResp.Total {
+			break
+		}
+	}
+
+	return connections, nil
+}
+
+// getConnection returns a single connection, limited to fields when any are
+// given.
+func (c *Auth0Client) getConnection(ctx context.Context, connectionId string, fields ...string) (*Auth0Connection, error) {
+	query := connectionListOptions{Fields: fields}.query()
+
+	var connection Auth0Connection
+	if err := c.doRequest(ctx, http.MethodGet, "connections/"+url.PathEscape(connectionId), query, nil, &connection); err != nil {
+		return nil, err
+	}
+
+	return &connection, nil
+}
+
+// getConnectionClients returns the IDs of the clients enabled for a
+// connection, from the cache when they have been read before.
+func (c *Auth0Client) getConnectionClients(ctx context.Context, connectionId string) ([]string, error) {
+	clients, err := c.cache.get(ctx, connectionClientsCacheKey(connectionId), func(ctx context.Context) (interface{}, error) {
+		return c.fetchConnectionClients(ctx, connectionId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{}, clients.([]string)...), nil
+}
+
+// fetchConnectionClients reads the IDs of the clients enabled for a
+// connection. It reads the paginated /connections/{id}/clients endpoint, or
+// the deprecated enabled_clients field when UseLegacyEnabledClients is set.
+func (c *Auth0Client) fetchConnectionClients(ctx context.Context, connectionId string) ([]string, error) {
+	if c.UseLegacyEnabledClients {
+		connection, err := c.getConnection(ctx, connectionId, "id", "enabled_clients")
+		if err != nil {
+			return nil, err
+		}
+
+		return connection.EnabledClients, nil
+	}
+
+	clients := []string{}
+	from := ""
+
+	for {
+		query := url.Values{}
+		query.Set("take", strconv.Itoa(connectionClientsPageSize))
+		if from != "" {
+			query.Set("from", from)
+		}
+
+		var clientsResp Auth0ConnectionClientsResponse
+		if err := c.doRequest(ctx, http.MethodGet, "connections/"+url.PathEscape(connectionId)+"/clients", query, nil, &clientsResp); err != nil {
+			return nil, err
+		}
+
+		for _, client := range clientsResp.Clients {
+			clients = append(clients, client.ClientId)
+		}
+
+		if clientsResp.Next == "" || len(clientsResp.Clients) == 0 {
+			break
+		}
+		from = clientsResp.Next
+	}
+
+	return clients, nil
+}
+
+// updateConnectionClients changes the enabled clients of a connection from
+// currentClients to newClients. Only the clients whose status changes are
+// sent to the /connections/{id}/clients endpoint, so concurrent changes to
+// other clients are kept, in as many updates as its per-request limit needs;
+// in legacy mode the whole enabled_clients field is replaced instead.
+func (c *Auth0Client) updateConnectionClients(ctx context.Context, connectionId string, currentClients []string, newClients []string) error {
+	if c.UseLegacyEnabledClients {
+		return c.updateConnectionEnabledClients(ctx, connectionId, newClients)
+	}
+
+	currentSet := make(map[string]bool, len(currentClients))
+	for _, clientId := range currentClients {
+		currentSet[clientId] = true
+	}
+
+	newSet := make(map[string]bool, len(newClients))
+	for _, clientId := range newClients {
+		newSet[clientId] = true
+	}
+
+	var changes []Auth0ConnectionClientStatus
+	for _, clientId := range newClients {
+		if !currentSet[clientId] {
+			changes = append(changes, Auth0ConnectionClientStatus{ClientId: clientId, Status: true})
+			currentSet[clientId] = true
+		}
+	}
+	for _, clientId := range currentClients {
+		if !newSet[clientId] {
+			changes = append(changes, Auth0ConnectionClientStatus{ClientId: clientId, Status: false})
+			newSet[clientId] = true
+		}
+	}
+
+	for start := 0; start < len(changes); start += connectionClientsUpdateLimit {
+		end := start + connectionClientsUpdateLimit
+		if end > len(changes) {
+			end = len(changes)
+		}
+
+		if err := c.doRequest(ctx, http.MethodPatch, "connections/"+url.PathEscape(connectionId)+"/clients", nil, changes[start:end], nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateConnectionEnabledClients replaces the deprecated enabled_clients
+// field of a connection.
+func (c *Auth0Client) updateConnectionEnabledClients(ctx context.Context, connectionId string, enabledClients []string) error {
+	if enabledClients == nil {
+		enabledClients = []string{}
+	}
+
+	payload := map[string]interface{}{
+		"enabled_clients": enabledClients,
+	}
+
+	return c.doRequest(ctx, http.MethodPatch, "connections/"+url.PathEscape(connectionId), nil, payload, nil)
+}
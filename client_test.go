@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/cerifi/terraform-provider-auth0-connections/internal/auth0fake"
+)
+
+// newTestClient returns a client of the fake tenant, configured like the
+// provider does with no optional settings.
+func newTestClient(t *testing.T, server *auth0fake.Server) *Auth0Client {
+	t.Helper()
+
+	httpClient, err := newHTTPClient(httpClientSettings{
+		RetryMaxAttempts: defaultRetryMaxAttempts,
+		RetryMaxWait:     defaultRetryMaxWait,
+	})
+	if err != nil {
+		t.Fatalf("newHTTPClient: %s", err)
+	}
+
+	return &Auth0Client{
+		Domain:           "tenant.example.com",
+		ClientId:         server.ClientID,
+		ClientSecret:     server.ClientSecret,
+		HTTPClient:       httpClient,
+		MaxConcurrency:   defaultMaxConcurrency,
+		WriteBatchWindow: 10 * time.Millisecond,
+		cache:            newAPICache(0),
+		APIBaseURL:       server.APIBaseURL(),
+		TokenURL:         server.TokenURL(),
+		Audience:         "https://tenant.example.com/api/v2/",
+	}
+}
+
+func newTestServer(t *testing.T) *auth0fake.Server {
+	t.Helper()
+
+	server := auth0fake.NewServer()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestListConnectionsPaginates(t *testing.T) {
+	server := newTestServer(t)
+	for i := 0; i < 250; i++ {
+		server.AddConnection(auth0fake.Connection{
+			ID:       fmt.Sprintf("con_%03d", i),
+			Name:     fmt.Sprintf("connection-%03d", i),
+			Strategy: "auth0",
+		})
+	}
+	client := newTestClient(t, server)
+
+	connections, err := client.listConnections(context.Background(), connectionListOptions{Fields: []string{"id"}})
+	if err != nil {
+		t.Fatalf("listConnections: %s", err)
+	}
+
+	if len(connections) != 250 {
+		t.Fatalf("got %d connections, want 250", len(connections))
+	}
+	if got := server.RequestCount(http.MethodGet, "/api/v2/connections"); got != 3 {
+		t.Errorf("got %d listing requests, want 3", got)
+	}
+	if connections[0].Name != "" {
+		t.Errorf("got name %q for a listing of ids only", connections[0].Name)
+	}
+}
+
+func TestListConnectionsFilters(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.AddConnection(auth0fake.Connection{ID: "con_2", Name: "google", Strategy: "google-oauth2"})
+	server.AddConnection(auth0fake.Connection{ID: "con_3", Name: "github", Strategy: "github"})
+	client := newTestClient(t, server)
+
+	connections, err := client.listConnections(context.Background(), connectionListOptions{
+		Strategies: []string{"google-oauth2", "github"},
+	})
+	if err != nil {
+		t.Fatalf("listConnections: %s", err)
+	}
+
+	if len(connections) != 2 || connections[0].Id != "con_2" || connections[1].Id != "con_3" {
+		t.Errorf("got %+v, want con_2 and con_3", connections)
+	}
+}
+
+func TestAccessTokenIsReused(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	client := newTestClient(t, server)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.getConnection(context.Background(), "con_1"); err != nil {
+			t.Fatalf("getConnection: %s", err)
+		}
+	}
+
+	if got := server.RequestCount(http.MethodPost, "/oauth/token"); got != 1 {
+		t.Errorf("got %d token requests, want 1", got)
+	}
+}
+
+func TestRateLimitIsRetried(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.InjectFault(auth0fake.RateLimitFault("/api/v2/connections/con_1", 1, time.Now()))
+	client := newTestClient(t, server)
+
+	if _, err := client.getConnection(context.Background(), "con_1"); err != nil {
+		t.Fatalf("getConnection: %s", err)
+	}
+
+	if got := server.RequestCount(http.MethodGet, "/api/v2/connections/con_1"); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestServerErrorsExhaustRetries(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.InjectFault(auth0fake.ServerErrorFault("/api/v2/connections/con_1", 0, http.StatusServiceUnavailable))
+	client := newTestClient(t, server)
+	client.HTTPClient.Transport.(*retryTransport).maxAttempts = 2
+
+	_, err := client.getConnection(context.Background(), "con_1")
+	if got := apiErrorStatus(err); got != http.StatusServiceUnavailable {
+		t.Fatalf("got error %v, want a 503", err)
+	}
+
+	if got := server.RequestCount(http.MethodGet, "/api/v2/connections/con_1"); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestInsufficientScope(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.SetScopes("read:connections")
+	client := newTestClient(t, server)
+
+	_, err := client.setConnectionClientEnabled(context.Background(), "con_1", "app_1", true)
+
+	var apiErr *Auth0APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want an Auth0APIError", err)
+	}
+	if apiErr.ErrorCode != "insufficient_scope" {
+		t.Errorf("got error code %q, want insufficient_scope", apiErr.ErrorCode)
+	}
+	if scopes := apiErr.MissingScopes(); len(scopes) != 1 || scopes[0] != "update:connections" {
+		t.Errorf("got missing scopes %v, want [update:connections]", scopes)
+	}
+}
+
+func TestConnectionClientsPaginates(t *testing.T) {
+	server := newTestServer(t)
+	var clients []string
+	for i := 0; i < 150; i++ {
+		clients = append(clients, fmt.Sprintf("app_%03d", i))
+	}
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0", EnabledClients: clients})
+	client := newTestClient(t, server)
+
+	got, err := client.getConnectionClients(context.Background(), "con_1")
+	if err != nil {
+		t.Fatalf("getConnectionClients: %s", err)
+	}
+
+	if len(got) != 150 {
+		t.Errorf("got %d clients, want 150", len(got))
+	}
+	if requests := server.RequestCount(http.MethodGet, "/api/v2/connections/con_1/clients"); requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestSetConnectionClientEnabledKeepsOtherClients(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		t.Run(fmt.Sprintf("legacy=%t", legacy), func(t *testing.T) {
+			server := newTestServer(t)
+			server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0", EnabledClients: []string{"other_app"}})
+			client := newTestClient(t, server)
+			client.UseLegacyEnabledClients = legacy
+
+			changed, err := client.setConnectionClientEnabled(context.Background(), "con_1", "app_1", true)
+			if err != nil {
+				t.Fatalf("enable: %s", err)
+			}
+			if !changed {
+				t.Errorf("got unchanged for a new client")
+			}
+			if got := server.ConnectionClients("con_1"); !stringSlicesEqual(got, []string{"app_1", "other_app"}) {
+				t.Errorf("got clients %v after enabling", got)
+			}
+
+			if _, err := client.setConnectionClientEnabled(context.Background(), "con_1", "app_1", false); err != nil {
+				t.Fatalf("disable: %s", err)
+			}
+			if got := server.ConnectionClients("con_1"); !stringSlicesEqual(got, []string{"other_app"}) {
+				t.Errorf("got clients %v after disabling", got)
+			}
+		})
+	}
+}
+
+func TestConcurrentChangesAreCoalesced(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	client := newTestClient(t, server)
+	client.WriteBatchWindow = 100 * time.Millisecond
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := client.setConnectionClientEnabled(context.Background(), "con_1", fmt.Sprintf("app_%d", i), true)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("setConnectionClientEnabled: %s", err)
+		}
+	}
+
+	if got := len(server.ConnectionClients("con_1")); got != 10 {
+		t.Errorf("got %d clients, want 10", got)
+	}
+	if got := server.RequestCount(http.MethodPatch, "/api/v2/connections/con_1/clients"); got != 1 {
+		t.Errorf("got %d updates, want 1", got)
+	}
+}
+
+func TestLargeCoalescedBatchIsChunked(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	client := newTestClient(t, server)
+	client.WriteBatchWindow = 100 * time.Millisecond
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 60)
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := client.setConnectionClientEnabled(context.Background(), "con_1", fmt.Sprintf("app_%02d", i), true)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("setConnectionClientEnabled: %s", err)
+		}
+	}
+
+	if got := len(server.ConnectionClients("con_1")); got != 60 {
+		t.Errorf("got %d clients, want 60", got)
+	}
+	if got := server.RequestCount(http.MethodPatch, "/api/v2/connections/con_1/clients"); got != 2 {
+		t.Errorf("got %d updates, want 2", got)
+	}
+}
+
+func TestRejectedChangeFailsOnlyItsWaiter(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.InjectFault(auth0fake.Fault{
+		Method:       http.MethodPatch,
+		Path:         "/api/v2/connections/con_1/clients",
+		BodyContains: "app_bad",
+		Status:       http.StatusBadRequest,
+		Body:         `{"statusCode":400,"error":"Bad Request","message":"Client app_bad does not exist","errorCode":"invalid_body"}`,
+	})
+	client := newTestClient(t, server)
+	client.WriteBatchWindow = 100 * time.Millisecond
+
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	for _, clientId := range []string{"app_good", "app_bad"} {
+		wg.Add(1)
+		go func(clientId string) {
+			defer wg.Done()
+			_, err := client.setConnectionClientEnabled(context.Background(), "con_1", clientId, true)
+			mu.Lock()
+			errs[clientId] = err
+			mu.Unlock()
+		}(clientId)
+	}
+	wg.Wait()
+
+	if err := errs["app_good"]; err != nil {
+		t.Errorf("got error %v for the valid client", err)
+	}
+	if err := errs["app_bad"]; apiErrorStatus(err) != http.StatusBadRequest || !isClientChangeError(err) {
+		t.Errorf("got error %v for the invalid client, want a 400 of its own change", err)
+	}
+	if got := server.ConnectionClients("con_1"); !stringSlicesEqual(got, []string{"app_good"}) {
+		t.Errorf("got clients %v, want only the valid one", got)
+	}
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.InjectFault(auth0fake.LatencyFault("/api/v2/connections/con_1", time.Second))
+	client := newTestClient(t, server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.getConnection(ctx, "con_1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the context deadline", err)
+	}
+}
+
+func TestGetOpenIDConfigurationDecodesErrors(t *testing.T) {
+	server := newTestServer(t)
+	server.InjectFault(auth0fake.Fault{
+		Path:   "/.well-known/openid-configuration",
+		Status: http.StatusNotFound,
+		Body:   `{"statusCode":404,"error":"Not Found","message":"Not found"}`,
+	})
+	client := newTestClient(t, server)
+
+	_, err := client.getOpenIDConfiguration(context.Background(), server.APIBaseURL())
+
+	var apiErr *Auth0APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got error %v, want an Auth0APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Not found" {
+		t.Errorf("got status %d and message %q", apiErr.StatusCode, apiErr.Message)
+	}
+}
+
+func TestUpdateConnectionClientsIsChunked(t *testing.T) {
+	server := newTestServer(t)
+	var currentClients, newClients []string
+	for i := 0; i < 30; i++ {
+		currentClients = append(currentClients, fmt.Sprintf("old_%03d", i))
+	}
+	for i := 0; i < 90; i++ {
+		newClients = append(newClients, fmt.Sprintf("new_%03d", i))
+	}
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0", EnabledClients: currentClients})
+	client := newTestClient(t, server)
+
+	if err := client.updateConnectionClients(context.Background(), "con_1", currentClients, newClients); err != nil {
+		t.Fatalf("updateConnectionClients: %s", err)
+	}
+
+	if got := server.ConnectionClients("con_1"); !stringSlicesEqual(got, newClients) {
+		t.Errorf("got %d clients, want the %d new ones", len(got), len(newClients))
+	}
+	if got := server.RequestCount(http.MethodPatch, "/api/v2/connections/con_1/clients"); got != 3 {
+		t.Errorf("got %d updates for 120 changes, want 3", got)
+	}
+}
+
+// newTestAssertionKey returns an RSA key registered with the fake tenant
+// under keyId.
+func newTestAssertionKey(t *testing.T, server *auth0fake.Server, keyId string) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %s", err)
+	}
+	server.AddClientAssertionKey(keyId, &key.PublicKey)
+
+	return key
+}
+
+func TestClientAssertionAuthenticates(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	key := newTestAssertionKey(t, server, "kid-1")
+
+	for _, alg := range []string{"RS256", "RS384", "PS256"} {
+		t.Run(alg, func(t *testing.T) {
+			client := newTestClient(t, server)
+			client.ClientSecret = ""
+			client.ClientAssertionKey = key
+			client.ClientAssertionKeyId = "kid-1"
+			client.ClientAssertionSigningAlg = alg
+
+			if _, err := client.getConnection(context.Background(), "con_1"); err != nil {
+				t.Fatalf("getConnection: %s", err)
+			}
+		})
+	}
+}
+
+func TestClientAssertionIsSignedAgainForRetries(t *testing.T) {
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_1", Name: "db", Strategy: "auth0"})
+	server.InjectFault(auth0fake.ServerErrorFault("/oauth/token", 1, http.StatusServiceUnavailable))
+	key := newTestAssertionKey(t, server, "kid-1")
+
+	client := newTestClient(t, server)
+	client.ClientSecret = ""
+	client.ClientAssertionKey = key
+	client.ClientAssertionKeyId = "kid-1"
+	client.ClientAssertionSigningAlg = "RS256"
+
+	if _, err := client.getConnection(context.Background(), "con_1"); err != nil {
+		t.Fatalf("getConnection: %s", err)
+	}
+
+	if got := server.RequestCount(http.MethodPost, "/oauth/token"); got != 2 {
+		t.Errorf("got %d token requests, want 2", got)
+	}
+}
+
+func TestClientAssertionCannotBeReused(t *testing.T) {
+	server := newTestServer(t)
+	key := newTestAssertionKey(t, server, "kid-1")
+
+	assertion, err := signClientAssertion(key, "RS256", "kid-1", server.ClientID, server.URL()+"/", time.Now())
+	if err != nil {
+		t.Fatalf("signClientAssertion: %s", err)
+	}
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_id":             {server.ClientID},
+		"client_assertion_type": {clientAssertionType},
+		"client_assertion":      {assertion},
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		resp, err := http.PostForm(server.TokenURL(), form)
+		if err != nil {
+			t.Fatalf("token request: %s", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Errorf("request %d: got status %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestClientAssertionIsVerified(t *testing.T) {
+	server := newTestServer(t)
+	key := newTestAssertionKey(t, server, "kid-1")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %s", err)
+	}
+
+	audience := server.URL() + "/"
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		key      *rsa.PrivateKey
+		keyId    string
+		clientId string
+		audience string
+		now      time.Time
+	}{
+		{"unregistered key", otherKey, "kid-1", server.ClientID, audience, now},
+		{"unknown key ID", key, "kid-2", server.ClientID, audience, now},
+		{"other client", key, "kid-1", "other-client", audience, now},
+		{"wrong audience", key, "kid-1", server.ClientID, server.TokenURL(), now},
+		{"expired", key, "kid-1", server.ClientID, audience, now.Add(-time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion, err := signClientAssertion(tt.key, "RS256", tt.keyId, tt.clientId, tt.audience, tt.now)
+			if err != nil {
+				t.Fatalf("signClientAssertion: %s", err)
+			}
+
+			resp, err := http.PostForm(server.TokenURL(), url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_id":             {server.ClientID},
+				"client_assertion_type": {clientAssertionType},
+				"client_assertion":      {assertion},
+			})
+			if err != nil {
+				t.Fatalf("token request: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("got status %d, want 401", resp.StatusCode)
+			}
+		})
+	}
+}
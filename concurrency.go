@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultMaxConcurrency is the number of Management API reads run in
+// parallel when max_concurrency is not configured.
+const defaultMaxConcurrency = 5
+
+// forEachConcurrently calls fn for every index in [0, n), running at most
+// limit calls at a time. Callers write results into index-addressed slots so
+// the output order doesn't depend on scheduling. The first error cancels the
+// context passed to the remaining calls and is returned.
+func forEachConcurrently(ctx context.Context, n int, limit int, fn func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	semaphore := make(chan struct{}, limit)
+
+	for i := 0; i < n; i++ {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if err := fn(ctx, i); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// The parent context may have been cancelled before all work started.
+	return ctx.Err()
+}
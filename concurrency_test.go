@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bitbucket.org/cerifi/terraform-provider-auth0-connections/internal/auth0fake"
+)
+
+func TestForEachConcurrentlyHoldsLimit(t *testing.T) {
+	var running, maxRunning int32
+	results := make([]int, 20)
+
+	err := forEachConcurrently(context.Background(), len(results), 3, func(ctx context.Context, i int) error {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&maxRunning)
+			if now <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, now) {
+				break
+			}
+		}
+
+		// Later indexes finish first, so scheduling order shows in results
+		// if they aren't kept in their slots.
+		time.Sleep(time.Duration(len(results)-i) * time.Millisecond)
+		results[i] = i * i
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("forEachConcurrently: %s", err)
+	}
+
+	if got := atomic.LoadInt32(&maxRunning); got != 3 {
+		t.Errorf("got %d calls running at once, want 3", got)
+	}
+	for i, result := range results {
+		if result != i*i {
+			t.Fatalf("got results %v, want squares in index order", results)
+		}
+	}
+}
+
+func TestForEachConcurrentlyCancelsOnFirstError(t *testing.T) {
+	failure := errors.New("failed")
+	var started int32
+
+	start := time.Now()
+	err := forEachConcurrently(context.Background(), 10, 2, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&started, 1)
+		if i == 0 {
+			return failure
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+
+	if !errors.Is(err, failure) {
+		t.Errorf("got error %v, want the first failure", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %s, want the remaining calls cancelled", elapsed)
+	}
+	if got := atomic.LoadInt32(&started); got > 2 {
+		t.Errorf("got %d calls started, want none after the failure", got)
+	}
+}
+
+func TestForEachConcurrentlyHonoursParentContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var started int32
+	err := forEachConcurrently(ctx, 10, 2, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&started, 1)
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want the cancellation", err)
+	}
+	if got := atomic.LoadInt32(&started); got != 0 {
+		t.Errorf("got %d calls started on a cancelled context", got)
+	}
+}
+
+func TestGetAllConnectionClientsStopsOnFailure(t *testing.T) {
+	server := newTestServer(t)
+	for i := 1; i <= 2; i++ {
+		server.AddConnection(auth0fake.Connection{ID: fmt.Sprintf("con_%d", i), Name: fmt.Sprintf("db-%d", i), Strategy: "auth0"})
+	}
+	server.InjectFault(auth0fake.Fault{
+		Path:   "/api/v2/connections/con_1/clients",
+		Status: http.StatusBadRequest,
+		Body:   `{"statusCode":400,"error":"Bad Request","message":"Invalid request","errorCode":"invalid_query_string"}`,
+	})
+	server.InjectFault(auth0fake.LatencyFault("/api/v2/connections/con_2/clients", 3*time.Second))
+	r := &ApplicationConnectionsResource{client: newTestClient(t, server)}
+
+	start := time.Now()
+	_, err := r.getAllConnectionClients(context.Background(), []string{"con_1", "con_2"})
+
+	if apiErrorStatus(err) != http.StatusBadRequest {
+		t.Errorf("got error %v, want the 400 of con_1", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %s, want the slow read cancelled", elapsed)
+	}
+}
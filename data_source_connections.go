@@ -2,12 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
@@ -25,6 +20,8 @@ type ConnectionsDataSource struct {
 // ConnectionsDataSourceModel describes the data source data model.
 type ConnectionsDataSourceModel struct {
 	Id            types.String      `tfsdk:"id"`
+	Strategies    types.List        `tfsdk:"strategies"`
+	Name          types.String      `tfsdk:"name"`
 	Connections   []ConnectionModel `tfsdk:"connections"`
 	ConnectionIds types.List        `tfsdk:"connection_ids"`
 	ConnectionMap types.Map         `tfsdk:"connection_map"`
@@ -39,23 +36,6 @@ type ConnectionModel struct {
 	Enabled     types.Bool   `tfsdk:"enabled"`
 }
 
-// Auth0 API response structure
-type Auth0ConnectionsResponse struct {
-	Connections []Auth0Connection `json:"connections"`
-	Total       int               `json:"total"`
-	Start       int               `json:"start"`
-	Limit       int               `json:"limit"`
-	Length      int               `json:"length"`
-}
-
-type Auth0Connection struct {
-	Id          string `json:"id"`
-	Name        string `json:"name"`
-	Strategy    string `json:"strategy"`
-	DisplayName string `json:"display_name"`
-	Enabled     bool   `json:"enabled"`
-}
-
 func NewConnectionsDataSource() datasource.DataSource {
 	return &ConnectionsDataSource{}
 }
@@ -73,8 +53,17 @@ func (d *ConnectionsDataSource) Schema(ctx context.Context, req datasource.Schem
 				MarkdownDescription: "Identifier of the data source",
 				Computed:            true,
 			},
+			"strategies": schema.ListAttribute{
+				MarkdownDescription: "Only return connections with one of these strategies (e.g., auth0, google-oauth2, samlp). Filtered by the Management API.",
+				ElementType:         types.StringType,
+				Optional:            true,
+			},
+			"name": schema.StringAttribute{
+				MarkdownDescription: "Only return the connection with this exact name. Filtered by the Management API.",
+				Optional:            true,
+			},
 			"connections": schema.ListNestedAttribute{
-				MarkdownDescription: "List of all Auth0 connections",
+				MarkdownDescription: "List of all Auth0 connections matching the filters",
 				Computed:            true,
 				NestedObject: schema.NestedAttributeObject{
 					Attributes: map[string]schema.Attribute{
@@ -145,23 +134,23 @@ func (d *ConnectionsDataSource) Read(ctx context.Context, req datasource.ReadReq
 		return
 	}
 
-	// Get access token
-	accessToken, err := d.getAccessToken(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get Auth0 access token",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
+	// Filter on the server and only fetch the fields this data source uses
+	opts := connectionListOptions{
+		Name:   data.Name.ValueString(),
+		Fields: []string{"id", "name", "strategy", "display_name", "enabled"},
+	}
+
+	if !data.Strategies.IsNull() {
+		resp.Diagnostics.Append(data.Strategies.ElementsAs(ctx, &opts.Strategies, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
 	}
 
 	// Fetch connections from Auth0 API
-	connections, err := d.fetchConnections(ctx, accessToken)
+	connections, err := d.client.listConnections(ctx, opts)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to fetch Auth0 connections",
-			fmt.Sprintf("Error: %s", err),
-		)
+		addAPIErrorDiagnostic(&resp.Diagnostics, "Failed to fetch Auth0 connections", err)
 		return
 	}
 
@@ -204,82 +193,3 @@ func (d *ConnectionsDataSource) Read(ctx context.Context, req datasource.ReadReq
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
-
-func (d *ConnectionsDataSource) getAccessToken(ctx context.Context) (string, error) {
-	// Auth0 Management API token endpoint
-	tokenURL := fmt.Sprintf("https://%s/oauth/token", d.client.Domain)
-
-	// Prepare the request body
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("client_id", d.client.ClientId)
-	data.Set("client_secret", d.client.ClientSecret)
-	data.Set("audience", fmt.Sprintf("https://%s/api/v2/", d.client.Domain))
-
-	// Create the request
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
-	if err != nil {
-		return "", fmt.Errorf("failed to create token request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	// Make the request
-	resp, err := d.client.HTTPClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make token request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse the response
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   int    `json:"expires_in"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
-	}
-
-	return tokenResp.AccessToken, nil
-}
-
-func (d *ConnectionsDataSource) fetchConnections(ctx context.Context, accessToken string) ([]Auth0Connection, error) {
-	// Auth0 Management API connections endpoint
-	connectionsURL := fmt.Sprintf("https://%s/api/v2/connections", d.client.Domain)
-
-	// Create the request
-	req, err := http.NewRequestWithContext(ctx, "GET", connectionsURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create connections request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Make the request
-	resp, err := d.client.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make connections request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("connections request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse the response - Auth0 API returns an array directly
-	var connections []Auth0Connection
-	if err := json.NewDecoder(resp.Body).Decode(&connections); err != nil {
-		return nil, fmt.Errorf("failed to decode connections response: %w", err)
-	}
-
-	return connections, nil
-}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccConnectionsDataSource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+data "auth0-connections_connections" "all" {}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "id", "auth0-connections"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "connections.#", "3"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "connection_ids.#", "3"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "connection_map.%", "3"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "connection_map.github", "con_github"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "connection_map.google-oauth2", "con_google"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.all", "connection_map.Username-Password-Authentication", "con_db"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.auth0-connections_connections.all", "connections.*", map[string]string{
+						"id":           "con_github",
+						"name":         "github",
+						"strategy":     "github",
+						"display_name": "GitHub",
+					}),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) + `
+data "auth0-connections_connections" "social" {
+  strategies = ["google-oauth2", "github"]
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.social", "connections.#", "2"),
+					resource.TestCheckTypeSetElemAttr("data.auth0-connections_connections.social", "connection_ids.*", "con_google"),
+					resource.TestCheckTypeSetElemAttr("data.auth0-connections_connections.social", "connection_ids.*", "con_github"),
+					resource.TestCheckNoResourceAttr("data.auth0-connections_connections.social", "connection_map.Username-Password-Authentication"),
+				),
+			},
+			{
+				Config: testAccProviderConfig(server) + `
+data "auth0-connections_connections" "named" {
+  name = "github"
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.named", "connection_ids.#", "1"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.named", "connection_ids.0", "con_github"),
+					resource.TestCheckResourceAttr("data.auth0-connections_connections.named", "connection_map.%", "1"),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+)
+
+// Auth0APIError is returned when the Management API or the token endpoint
+// answers with an unexpected status. Rate limits and server errors reach
+// callers only once the retry layer has given up on them.
+type Auth0APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+
+	// ErrorName, Message and ErrorCode are decoded from Auth0's error
+	// payload. Token endpoint errors use the OAuth error format instead,
+	// where the error name doubles as the error code.
+	ErrorName string
+	Message   string
+	ErrorCode string
+}
+
+// newAuth0APIError builds an Auth0APIError from a failed response, decoding
+// the Auth0 error payload when the body contains one.
+func newAuth0APIError(method string, apiPath string, statusCode int, body []byte) *Auth0APIError {
+	apiErr := &Auth0APIError{
+		Method:     method,
+		Path:       apiPath,
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+
+	var payload struct {
+		Error            string `json:"error"`
+		Message          string `json:"message"`
+		ErrorCode        string `json:"errorCode"`
+		ErrorDescription string `json:"error_description"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return apiErr
+	}
+
+	apiErr.ErrorName = payload.Error
+	apiErr.Message = payload.Message
+	apiErr.ErrorCode = payload.ErrorCode
+
+	if payload.ErrorDescription != "" {
+		apiErr.Message = payload.ErrorDescription
+		if apiErr.ErrorCode == "" {
+			apiErr.ErrorCode = payload.Error
+		}
+	}
+
+	return apiErr
+}
+
+func (e *Auth0APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s request failed with status %d: %s", e.Method, e.Path, e.StatusCode, e.Body)
+	}
+
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("%s %s request failed with status %d: %s (%s)", e.Method, e.Path, e.StatusCode, e.Message, e.ErrorCode)
+	}
+
+	return fmt.Sprintf("%s %s request failed with status %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// missingScopesPattern matches the scope list of Auth0's insufficient_scope
+// message, e.g. "Insufficient scope, expected any of: read:connections".
+var missingScopesPattern = regexp.MustCompile(`(?:any|all) of:\s*(.+)$`)
+
+// MissingScopes returns the scopes named by an insufficient_scope error.
+func (e *Auth0APIError) MissingScopes() []string {
+	match := missingScopesPattern.FindStringSubmatch(e.Message)
+	if match == nil {
+		return nil
+	}
+
+	var scopes []string
+	for _, scope := range strings.Split(match[1], ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// attributeError ties an error to the resource attribute that caused it, so
+// its diagnostic can point at that attribute.
+type attributeError struct {
+	path path.Path
+	err  error
+}
+
+func (e *attributeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *attributeError) Unwrap() error {
+	return e.err
+}
+
+// withAttributePath marks err as caused by the attribute at attributePath.
+func withAttributePath(attributePath path.Path, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &attributeError{path: attributePath, err: err}
+}
+
+// apiErrorStatus returns the status code of an Auth0APIError in err's chain,
+// or 0 when err isn't one.
+func apiErrorStatus(err error) int {
+	var apiErr *Auth0APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	return 0
+}
+
+// isNotFound reports whether err is a 404 from the Management API.
+func isNotFound(err error) bool {
+	return apiErrorStatus(err) == http.StatusNotFound
+}
+
+// addAPIErrorDiagnostic appends err to diags as an error diagnostic with a
+// remediation hint for known Auth0 failures. The diagnostic points at the
+// attribute that caused err when it was marked with withAttributePath.
+func addAPIErrorDiagnostic(diags *diag.Diagnostics, summary string, err error) {
+	detail := fmt.Sprintf("Error: %s", err)
+
+	var apiErr *Auth0APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Message != "" {
+			summary = fmt.Sprintf("%s: %s", summary, apiErr.Message)
+		}
+		if hint := apiErrorHint(apiErr); hint != "" {
+			detail = hint + "\n\n" + detail
+		}
+	}
+
+	var attrErr *attributeError
+	if errors.As(err, &attrErr) {
+		diags.AddAttributeError(attrErr.path, summary, detail)
+		return
+	}
+
+	diags.AddError(summary, detail)
+}
+
+// apiErrorHint returns how to fix a known Auth0 failure, by error code first
+// and by status code otherwise.
+func apiErrorHint(apiErr *Auth0APIError) string {
+	switch apiErr.ErrorCode {
+	case "insufficient_scope":
+		scopes := apiErr.MissingScopes()
+		if len(scopes) == 0 {
+			scopes = []string{"read:connections", "update:connections"}
+		}
+		return fmt.Sprintf("The provider's access token is missing a required scope. "+
+			"Grant %s to the provider's client under the Auth0 Management API's Machine to Machine Applications settings.", strings.Join(scopes, ", "))
+	case "invalid_client":
+		return "Auth0 does not recognize the provider's client. " +
+			"Check client_id and client_secret, or client_assertion_private_key and client_assertion_key_id, of the provider configuration."
+	case "unauthorized_client":
+		return "The provider's client is not allowed to use the client credentials grant. " +
+			"Enable the Client Credentials grant type in the application's advanced settings."
+	case "access_denied":
+		return "Auth0 refused to issue a Management API token. " +
+			"Check that the provider's client is authorized for the Management API and that audience matches the tenant's Management API identifier."
+	case "invalid_token":
+		return "Auth0 rejected the Management API token. " +
+			"If api_token is configured, make sure it was issued for this tenant's Management API and has not been revoked."
+	case "too_many_requests":
+		return "The Auth0 Management API rate limit was still exceeded after retrying. " +
+			"Lower max_concurrency or raise retry_max_attempts and retry_max_wait."
+	case "inexistent_connection":
+		return "The connection does not exist in the tenant. Remove it from the configuration or check its ID."
+	}
+
+	switch status := apiErr.StatusCode; {
+	case status == http.StatusUnauthorized:
+		return "Auth0 rejected the provider's credentials or access token. " +
+			"Check the client_id and client_secret (or api_token) of the provider configuration."
+	case status == http.StatusForbidden:
+		return "The provider's access token is missing a required scope. " +
+			"Authorize the provider's client for the Auth0 Management API with the read:connections and update:connections scopes."
+	case status == http.StatusTooManyRequests:
+		return "The Auth0 Management API rate limit was still exceeded after retrying. " +
+			"Lower max_concurrency or raise retry_max_attempts and retry_max_wait."
+	case status >= http.StatusInternalServerError:
+		return "The Auth0 Management API kept failing after retrying. Try again later."
+	}
+
+	return ""
+}
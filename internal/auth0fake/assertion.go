@@ -0,0 +1,159 @@
+package auth0fake
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clientAssertionType is the client_assertion_type of Private Key JWT client
+// authentication.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionHashes are the hashes of the supported signing algorithms.
+var clientAssertionHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"PS256": crypto.SHA256,
+}
+
+// AddClientAssertionKey registers the public key of a Private Key JWT
+// credential of ClientID. Client assertions must be signed by the key named
+// by their kid header, or by any registered key when they have none.
+func (s *Server) AddClientAssertionKey(keyID string, key *rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assertionKeys[keyID] = key
+}
+
+// recordClientAssertionID counts the jti of the client assertion in a token
+// request body. Like Auth0, which may have consumed an assertion before a
+// request fails, it counts requests answered by a fault too. s.mu must be
+// held.
+func (s *Server) recordClientAssertionID(body []byte) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return
+	}
+
+	parts := strings.Split(form.Get("client_assertion"), ".")
+	if len(parts) != 3 {
+		return
+	}
+
+	var claims struct {
+		Jti string `json:"jti"`
+	}
+	if err := decodeSegment(parts[1], &claims); err == nil && claims.Jti != "" {
+		s.assertionIDs[claims.Jti]++
+	}
+}
+
+// verifyClientAssertion checks the signature of a client assertion against
+// the registered keys, that it is issued by and for ClientID, that it is
+// meant for audience, that it has not expired and that its jti was not used
+// before.
+func (s *Server) verifyClientAssertion(assertion string, audience string, now time.Time) error {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return errors.New("client assertion is not a JWT")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("invalid client assertion header: %w", err)
+	}
+
+	hash, ok := clientAssertionHashes[header.Alg]
+	if !ok {
+		return fmt.Errorf("unsupported client assertion algorithm %q", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("invalid client assertion signature: %w", err)
+	}
+
+	s.mu.Lock()
+	var keys []*rsa.PublicKey
+	if header.Kid != "" {
+		if key, ok := s.assertionKeys[header.Kid]; ok {
+			keys = append(keys, key)
+		}
+	} else {
+		for _, key := range s.assertionKeys {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.Unlock()
+
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	digest := hasher.Sum(nil)
+
+	verified := false
+	for _, key := range keys {
+		if strings.HasPrefix(header.Alg, "PS") {
+			verified = rsa.VerifyPSS(key, hash, digest, signature, nil) == nil
+		} else {
+			verified = rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+		}
+		if verified {
+			break
+		}
+	}
+	if !verified {
+		return errors.New("client assertion is not signed by a registered key")
+	}
+
+	var claims struct {
+		Iss string `json:"iss"`
+		Sub string `json:"sub"`
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Jti string `json:"jti"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("invalid client assertion claims: %w", err)
+	}
+
+	if claims.Iss != s.ClientID || claims.Sub != s.ClientID {
+		return fmt.Errorf("client assertion iss %q and sub %q must be the client ID", claims.Iss, claims.Sub)
+	}
+	if claims.Aud != audience {
+		return fmt.Errorf("client assertion aud %q must be %q", claims.Aud, audience)
+	}
+	if claims.Exp == 0 || !now.Before(time.Unix(claims.Exp, 0)) {
+		return errors.New("client assertion has expired")
+	}
+
+	s.mu.Lock()
+	uses := s.assertionIDs[claims.Jti]
+	s.mu.Unlock()
+	if claims.Jti == "" || uses > 1 {
+		return fmt.Errorf("client assertion jti %q must be unique", claims.Jti)
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,934 @@
+// Package auth0fake provides an in-process fake of the parts of the Auth0
+// Management API used by the provider, for unit and acceptance tests that
+// run offline.
+//
+// The fake keeps tenant state in memory and implements:
+//
+//   - POST /oauth/token (client credentials, with a secret or a Private Key
+//     JWT assertion verified against AddClientAssertionKey, whose jti may
+//     only be used once)
+//   - GET /.well-known/openid-configuration (the issuer of the host asked)
+//   - GET /.well-known/jwks.json (the tenant's signing key ID, without key
+//     material)
+//   - GET /api/v2/connections (page/per_page, include_totals, strategy, name,
+//     fields/include_fields)
+//   - GET and PATCH /api/v2/connections/{id} (the enabled_clients field)
+//   - GET and PATCH /api/v2/connections/{id}/clients (from/take checkpoints,
+//     at most 50 changes per PATCH)
+//   - GET /api/v2/clients (page/per_page, include_totals)
+//
+// Faults such as rate limits, server errors, latency and missing scopes can
+// be scripted with InjectFault and SetScopes.
+package auth0fake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connection is a connection of the fake tenant.
+type Connection struct {
+	ID             string
+	Name           string
+	Strategy       string
+	DisplayName    string
+	EnabledClients []string
+}
+
+// Client is an application of the fake tenant.
+type Client struct {
+	ID   string
+	Name string
+}
+
+// Fault makes matching requests fail or slow down.
+type Fault struct {
+	// Method and Path select the requests the fault applies to. An empty
+	// Method matches every method; Path matches as a prefix of the request
+	// path, e.g. "/api/v2/connections".
+	Method string
+	Path   string
+
+	// BodyContains, when not empty, limits the fault to requests whose body
+	// contains it, e.g. one client ID of a PATCH.
+	BodyContains string
+
+	// Times is the number of matching requests the fault applies to; zero
+	// applies it to every matching request.
+	Times int
+
+	// Delay is added before the request is answered.
+	Delay time.Duration
+
+	// Status, when not zero, answers the request with this status code,
+	// Header and Body instead of handling it.
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// RateLimitFault answers times requests to path with a 429 whose rate limit
+// headers ask to wait until reset.
+func RateLimitFault(path string, times int, reset time.Time) Fault {
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", "10")
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+	return Fault{
+		Path:   path,
+		Times:  times,
+		Status: http.StatusTooManyRequests,
+		Header: header,
+		Body:   errorBody(http.StatusTooManyRequests, "Too Many Requests", "Global limit has been reached", "too_many_requests"),
+	}
+}
+
+// ServerErrorFault answers times requests to path with the given 5xx status.
+func ServerErrorFault(path string, times int, status int) Fault {
+	return Fault{
+		Path:   path,
+		Times:  times,
+		Status: status,
+		Body:   errorBody(status, http.StatusText(status), "Internal error", ""),
+	}
+}
+
+// LatencyFault delays every request to path by delay.
+func LatencyFault(path string, delay time.Duration) Fault {
+	return Fault{
+		Path:  path,
+		Delay: delay,
+	}
+}
+
+// maxConnectionClientChanges is the maximum number of client status changes
+// accepted by a PATCH of /api/v2/connections/{id}/clients.
+const maxConnectionClientChanges = 50
+
+// DefaultScopes are the Management API scopes granted unless SetScopes is
+// called.
+var DefaultScopes = []string{"read:connections", "update:connections", "read:clients"}
+
+// Server is a running fake Auth0 tenant.
+type Server struct {
+	// ClientID and ClientSecret are the credentials accepted by the token
+	// endpoint. Requests with a client_assertion must instead sign it with a
+	// key registered by AddClientAssertionKey.
+	ClientID     string
+	ClientSecret string
+
+	// TokenLifetime is the expires_in of issued access tokens.
+	TokenLifetime time.Duration
+
+	// SigningKeyID is the key ID published at every host of the tenant, so
+	// hosts of one tenant can be told apart from those of another.
+	SigningKeyID string
+
+	httpServer    *httptest.Server
+	customDomains []*httptest.Server
+
+	mu            sync.Mutex
+	connections   map[string]*Connection
+	clients       map[string]*Client
+	scopes        map[string]bool
+	tokens        map[string]bool
+	assertionKeys map[string]*rsa.PublicKey
+	assertionIDs  map[string]int
+	faults        []*Fault
+	requests      map[string]int
+}
+
+// NewServer starts a fake tenant without connections or clients, accepting
+// the credentials "client-id" and "client-secret".
+func NewServer() *Server {
+	s := &Server{
+		ClientID:      "client-id",
+		ClientSecret:  "client-secret",
+		TokenLifetime: 24 * time.Hour,
+		SigningKeyID:  newSigningKeyID(),
+		connections:   make(map[string]*Connection),
+		clients:       make(map[string]*Client),
+		tokens:        make(map[string]bool),
+		assertionKeys: make(map[string]*rsa.PublicKey),
+		assertionIDs:  make(map[string]int),
+		requests:      make(map[string]int),
+	}
+	s.SetScopes(DefaultScopes...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", s.handleToken)
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleOpenIDConfiguration)
+	mux.HandleFunc("/.well-known/jwks.json", s.handleJWKS)
+	mux.HandleFunc("/api/v2/connections", s.authorized(s.handleConnections))
+	mux.HandleFunc("/api/v2/connections/", s.authorized(s.handleConnection))
+	mux.HandleFunc("/api/v2/clients", s.authorized(s.handleClients))
+
+	s.httpServer = httptest.NewServer(s.withFaults(mux))
+
+	return s
+}
+
+// Close shuts the server and its custom domains down.
+func (s *Server) Close() {
+	for _, customDomain := range s.customDomains {
+		customDomain.Close()
+	}
+	s.httpServer.Close()
+}
+
+// AddCustomDomain starts another host serving the same tenant, as an Auth0
+// custom domain does, and returns its base URL. Like every Auth0 host, it
+// reports itself as issuer in its OpenID configuration.
+func (s *Server) AddCustomDomain() string {
+	customDomain := httptest.NewServer(s.httpServer.Config.Handler)
+	s.customDomains = append(s.customDomains, customDomain)
+	return customDomain.URL
+}
+
+// URL returns the base URL of the server, e.g. http://127.0.0.1:1234.
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// APIBaseURL returns the Management API base URL, for api_base_url.
+func (s *Server) APIBaseURL() string {
+	return s.httpServer.URL + "/api/v2/"
+}
+
+// TokenURL returns the token endpoint URL, for token_url.
+func (s *Server) TokenURL() string {
+	return s.httpServer.URL + "/oauth/token"
+}
+
+// AddConnection adds or replaces a connection.
+func (s *Server) AddConnection(connection Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	connection.EnabledClients = uniqueSorted(connection.EnabledClients)
+	s.connections[connection.ID] = &connection
+}
+
+// DeleteConnection removes a connection.
+func (s *Server) DeleteConnection(connectionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.connections, connectionID)
+}
+
+// Connection returns a copy of a connection.
+func (s *Server) Connection(connectionID string) (Connection, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	connection, ok := s.connections[connectionID]
+	if !ok {
+		return Connection{}, false
+	}
+
+	copied := *connection
+	copied.EnabledClients = append([]string{}, connection.EnabledClients...)
+	return copied, true
+}
+
+// ConnectionClients returns the sorted IDs of the clients enabled for a
+// connection.
+func (s *Server) ConnectionClients(connectionID string) []string {
+	connection, _ := s.Connection(connectionID)
+	return connection.EnabledClients
+}
+
+// SetConnectionClient enables or disables a client on a connection, as a
+// change made outside the provider would.
+func (s *Server) SetConnectionClient(connectionID string, clientID string, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if connection, ok := s.connections[connectionID]; ok {
+		connection.EnabledClients = setMembership(connection.EnabledClients, clientID, enabled)
+	}
+}
+
+// AddClient adds or replaces a client.
+func (s *Server) AddClient(client Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clients[client.ID] = &client
+}
+
+// IssueToken returns a Management API access token valid for lifetime, as
+// handed out by a token broker instead of the token endpoint. It is a JWT
+// whose exp claim is the end of lifetime; its signature is not checked.
+func (s *Server) IssueToken(lifetime time.Duration) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss": s.httpServer.URL + "/",
+		"aud": s.APIBaseURL(),
+		"exp": time.Now().Add(lifetime).Unix(),
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := fmt.Sprintf("%s.%s.%s",
+		base64.RawURLEncoding.EncodeToString(header),
+		base64.RawURLEncoding.EncodeToString(claims),
+		base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("fake-signature-%d", len(s.tokens)+1))))
+	s.tokens[token] = true
+
+	return token
+}
+
+// SetScopes replaces the scopes granted to issued and future access tokens.
+func (s *Server) SetScopes(scopes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scopes = make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		s.scopes[scope] = true
+	}
+}
+
+// InjectFault adds a fault. Faults are matched in the order they were added.
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes every fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// RequestCount returns how many requests were received for method and the
+// exact request path, including requests answered by a fault.
+func (s *Server) RequestCount(method string, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[method+" "+path]
+}
+
+// TotalRequestCount returns how many requests were received for method on
+// any path with the given prefix.
+func (s *Server) TotalRequestCount(method string, pathPrefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for key, count := range s.requests {
+		if strings.HasPrefix(key, method+" "+pathPrefix) {
+			total += count
+		}
+	}
+	return total
+}
+
+func (s *Server) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The body is read up front for BodyContains and restored for the
+		// handler.
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		if r.URL.Path == "/oauth/token" {
+			s.recordClientAssertionID(body)
+		}
+
+		var delay time.Duration
+		var failure *Fault
+		for _, fault := range s.faults {
+			if fault.Method != "" && fault.Method != r.Method {
+				continue
+			}
+			if !strings.HasPrefix(r.URL.Path, fault.Path) {
+				continue
+			}
+			if fault.BodyContains != "" && !bytes.Contains(body, []byte(fault.BodyContains)) {
+				continue
+			}
+			if fault.Times < 0 {
+				continue
+			}
+			if fault.Times > 0 {
+				fault.Times--
+				if fault.Times == 0 {
+					// Exhausted faults never match again.
+					fault.Times = -1
+				}
+			}
+
+			delay += fault.Delay
+			if fault.Status != 0 {
+				failure = fault
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if failure != nil {
+			for key, values := range failure.Header {
+				for _, value := range values {
+					w.Header().Add(key, value)
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(failure.Status)
+			fmt.Fprint(w, failure.Body)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only POST is supported", "")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeOAuthError(w, http.StatusForbidden, "unauthorized_client", "Grant type not allowed for the client.")
+		return
+	}
+
+	if r.PostForm.Get("client_id") != s.ClientID {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Unauthorized")
+		return
+	}
+
+	if assertion := r.PostForm.Get("client_assertion"); assertion != "" {
+		if r.PostForm.Get("client_assertion_type") != clientAssertionType {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Unsupported client_assertion_type.")
+			return
+		}
+
+		// Auth0 expects the origin of the token endpoint as audience.
+		if err := s.verifyClientAssertion(assertion, "http://"+r.Host+"/", time.Now()); err != nil {
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+			return
+		}
+	} else if r.PostForm.Get("client_secret") != s.ClientSecret {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Unauthorized")
+		return
+	}
+
+	s.mu.Lock()
+	token := fmt.Sprintf("fake-token-%d", len(s.tokens)+1)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(s.TokenLifetime.Seconds()),
+	})
+}
+
+func (s *Server) handleOpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only GET is supported", "")
+		return
+	}
+
+	issuer := "http://" + r.Host + "/"
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":         issuer,
+		"token_endpoint": issuer + "oauth/token",
+		"jwks_uri":       issuer + ".well-known/jwks.json",
+	})
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only GET is supported", "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{
+			{"kid": s.SigningKeyID, "kty": "RSA", "use": "sig", "alg": "RS256"},
+		},
+	})
+}
+
+// newSigningKeyID returns a random key ID, unique to each fake tenant.
+func newSigningKeyID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// authorized rejects requests without a token issued by the server.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		valid := s.tokens[token]
+		s.mu.Unlock()
+
+		if !valid {
+			writeError(w, http.StatusUnauthorized, "Unauthorized", "Invalid token", "invalid_token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requireScope answers with Auth0's insufficient_scope error when scope
+// isn't granted, and reports whether it is.
+func (s *Server) requireScope(w http.ResponseWriter, scope string) bool {
+	s.mu.Lock()
+	granted := s.scopes[scope]
+	s.mu.Unlock()
+
+	if !granted {
+		writeError(w, http.StatusForbidden, "Forbidden", "Insufficient scope, expected any of: "+scope, "insufficient_scope")
+	}
+	return granted
+}
+
+func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only GET is supported", "")
+		return
+	}
+	if !s.requireScope(w, "read:connections") {
+		return
+	}
+
+	query := r.URL.Query()
+	strategies := make(map[string]bool)
+	for _, strategy := range query["strategy"] {
+		strategies[strategy] = true
+	}
+
+	s.mu.Lock()
+	var matches []map[string]interface{}
+	for _, id := range s.sortedConnectionIDs() {
+		connection := s.connections[id]
+		if len(strategies) > 0 && !strategies[connection.Strategy] {
+			continue
+		}
+		if name := query.Get("name"); name != "" && connection.Name != name {
+			continue
+		}
+		matches = append(matches, connectionJSON(connection, query))
+	}
+	s.mu.Unlock()
+
+	page, perPage, ok := pagination(w, query)
+	if !ok {
+		return
+	}
+
+	items := pageOf(matches, page, perPage)
+
+	if query.Get("include_totals") == "true" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"connections": items,
+			"start":       page * perPage,
+			"limit":       perPage,
+			"length":      len(items),
+			"total":       len(matches),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) handleConnection(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v2/connections/"), "/")
+	connectionID := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		s.handleConnectionObject(w, r, connectionID)
+	case len(parts) == 2 && parts[1] == "clients":
+		s.handleConnectionClients(w, r, connectionID)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found", "Not Found", "")
+	}
+}
+
+func (s *Server) handleConnectionObject(w http.ResponseWriter, r *http.Request, connectionID string) {
+	switch r.Method {
+	case http.MethodGet:
+		if !s.requireScope(w, "read:connections") {
+			return
+		}
+
+		s.mu.Lock()
+		connection, ok := s.connections[connectionID]
+		var body map[string]interface{}
+		if ok {
+			body = connectionJSON(connection, r.URL.Query())
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found", "The connection does not exist", "inexistent_connection")
+			return
+		}
+		writeJSON(w, http.StatusOK, body)
+
+	case http.MethodPatch:
+		if !s.requireScope(w, "update:connections") {
+			return
+		}
+
+		var payload struct {
+			EnabledClients *[]string `json:"enabled_clients"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request", "Payload validation error: "+err.Error(), "invalid_body")
+			return
+		}
+
+		s.mu.Lock()
+		connection, ok := s.connections[connectionID]
+		var body map[string]interface{}
+		if ok {
+			if payload.EnabledClients != nil {
+				connection.EnabledClients = uniqueSorted(*payload.EnabledClients)
+			}
+			body = connectionJSON(connection, nil)
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found", "The connection does not exist", "inexistent_connection")
+			return
+		}
+		writeJSON(w, http.StatusOK, body)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only GET and PATCH are supported", "")
+	}
+}
+
+func (s *Server) handleConnectionClients(w http.ResponseWriter, r *http.Request, connectionID string) {
+	switch r.Method {
+	case http.MethodGet:
+		if !s.requireScope(w, "read:connections") {
+			return
+		}
+
+		query := r.URL.Query()
+		take := 50
+		if value := query.Get("take"); value != "" {
+			var err error
+			if take, err = strconv.Atoi(value); err != nil || take < 1 || take > 100 {
+				writeError(w, http.StatusBadRequest, "Bad Request", "Query validation error: invalid take", "invalid_query_string")
+				return
+			}
+		}
+		from := 0
+		if value := query.Get("from"); value != "" {
+			var err error
+			if from, err = strconv.Atoi(value); err != nil || from < 0 {
+				writeError(w, http.StatusBadRequest, "Bad Request", "Query validation error: invalid from", "invalid_query_string")
+				return
+			}
+		}
+
+		s.mu.Lock()
+		connection, ok := s.connections[connectionID]
+		var enabledClients []string
+		if ok {
+			enabledClients = append(enabledClients, connection.EnabledClients...)
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found", "The connection does not exist", "inexistent_connection")
+			return
+		}
+
+		clients := []map[string]string{}
+		for i := from; i < len(enabledClients) && i < from+take; i++ {
+			clients = append(clients, map[string]string{"client_id": enabledClients[i]})
+		}
+
+		body := map[string]interface{}{"clients": clients}
+		if from+take < len(enabledClients) {
+			body["next"] = strconv.Itoa(from + take)
+		}
+		writeJSON(w, http.StatusOK, body)
+
+	case http.MethodPatch:
+		if !s.requireScope(w, "update:connections") {
+			return
+		}
+
+		var changes []struct {
+			ClientID string `json:"client_id"`
+			Status   *bool  `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request", "Payload validation error: "+err.Error(), "invalid_body")
+			return
+		}
+		if len(changes) > maxConnectionClientChanges {
+			writeError(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("Payload validation error: 'Too many items (%d), max %d' on property body.", len(changes), maxConnectionClientChanges), "invalid_body")
+			return
+		}
+		for _, change := range changes {
+			if change.ClientID == "" || change.Status == nil {
+				writeError(w, http.StatusBadRequest, "Bad Request", "Payload validation error: client_id and status are required", "invalid_body")
+				return
+			}
+		}
+
+		s.mu.Lock()
+		connection, ok := s.connections[connectionID]
+		if ok {
+			for _, change := range changes {
+				connection.EnabledClients = setMembership(connection.EnabledClients, change.ClientID, *change.Status)
+			}
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found", "The connection does not exist", "inexistent_connection")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only GET and PATCH are supported", "")
+	}
+}
+
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", "Only GET is supported", "")
+		return
+	}
+	if !s.requireScope(w, "read:clients") {
+		return
+	}
+
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.clients))
+	for id := range s.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var clients []map[string]interface{}
+	for _, id := range ids {
+		clients = append(clients, map[string]interface{}{
+			"client_id": id,
+			"name":      s.clients[id].Name,
+		})
+	}
+	s.mu.Unlock()
+
+	query := r.URL.Query()
+	page, perPage, ok := pagination(w, query)
+	if !ok {
+		return
+	}
+
+	items := pageOf(clients, page, perPage)
+
+	if query.Get("include_totals") == "true" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"clients": items,
+			"start":   page * perPage,
+			"limit":   perPage,
+			"length":  len(items),
+			"total":   len(clients),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// sortedConnectionIDs must be called with s.mu held.
+func (s *Server) sortedConnectionIDs() []string {
+	ids := make([]string, 0, len(s.connections))
+	for id := range s.connections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// connectionJSON renders a connection, honouring fields/include_fields.
+func connectionJSON(connection *Connection, query map[string][]string) map[string]interface{} {
+	all := map[string]interface{}{
+		"id":              connection.ID,
+		"name":            connection.Name,
+		"strategy":        connection.Strategy,
+		"display_name":    connection.DisplayName,
+		"enabled_clients": append([]string{}, connection.EnabledClients...),
+		"options": map[string]interface{}{
+			"signing_cert": strings.Repeat("A", 2048),
+		},
+	}
+
+	fields := ""
+	includeFields := "true"
+	if query != nil {
+		if values := query["fields"]; len(values) > 0 {
+			fields = values[0]
+		}
+		if values := query["include_fields"]; len(values) > 0 {
+			includeFields = values[0]
+		}
+	}
+	if fields == "" {
+		return all
+	}
+
+	selected := make(map[string]bool)
+	for _, field := range strings.Split(fields, ",") {
+		selected[strings.TrimSpace(field)] = true
+	}
+
+	result := make(map[string]interface{})
+	for key, value := range all {
+		if selected[key] == (includeFields != "false") {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func pagination(w http.ResponseWriter, query map[string][]string) (int, int, bool) {
+	page, perPage := 0, 50
+
+	if values := query["page"]; len(values) > 0 {
+		var err error
+		if page, err = strconv.Atoi(values[0]); err != nil || page < 0 {
+			writeError(w, http.StatusBadRequest, "Bad Request", "Query validation error: invalid page", "invalid_query_string")
+			return 0, 0, false
+		}
+	}
+	if values := query["per_page"]; len(values) > 0 {
+		var err error
+		if perPage, err = strconv.Atoi(values[0]); err != nil || perPage < 1 || perPage > 100 {
+			writeError(w, http.StatusBadRequest, "Bad Request", "Query validation error: invalid per_page", "invalid_query_string")
+			return 0, 0, false
+		}
+	}
+
+	return page, perPage, true
+}
+
+func pageOf(items []map[string]interface{}, page int, perPage int) []map[string]interface{} {
+	start := page * perPage
+	if start >= len(items) {
+		return []map[string]interface{}{}
+	}
+
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+func setMembership(clients []string, clientID string, enabled bool) []string {
+	var result []string
+	for _, id := range clients {
+		if id != clientID {
+			result = append(result, id)
+		}
+	}
+	if enabled {
+		result = append(result, clientID)
+	}
+	return uniqueSorted(result)
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func errorBody(status int, name string, message string, errorCode string) string {
+	body := map[string]interface{}{
+		"statusCode": status,
+		"error":      name,
+		"message":    message,
+	}
+	if errorCode != "" {
+		body["errorCode"] = errorCode
+	}
+
+	data, _ := json.Marshal(body)
+	return string(data)
+}
+
+func writeError(w http.ResponseWriter, status int, name string, message string, errorCode string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprint(w, errorBody(status, name, message, errorCode))
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, errorCode string, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             errorCode,
+		"error_description": description,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// jwtExpiry returns the time of the exp claim of a JWT access token, without
+// verifying its signature. Tokens that aren't JWTs, or JWTs without an exp
+// claim, have no known expiry and yield the zero time.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode token payload: %w", err)
+	}
+
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode token claims: %w", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, nil
+	}
+
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid exp claim %q: %w", claims.Exp.String(), err)
+	}
+
+	return time.Unix(int64(exp), 0), nil
+}
+
+// clientAssertionType is the client_assertion_type of Private Key JWT client
+// authentication.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionLifetime is how long a signed client assertion is valid.
+const clientAssertionLifetime = 2 * time.Minute
+
+// clientAssertionSigningAlgs are the supported client assertion algorithms.
+var clientAssertionSigningAlgs = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"PS256": crypto.SHA256,
+}
+
+// loadClientAssertionKey parses an RSA private key given either as inline
+// PEM or as the path to a PEM file.
+func loadClientAssertionKey(value string) (*rsa.PrivateKey, error) {
+	pemData := []byte(value)
+	if !strings.Contains(value, "-----BEGIN") {
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key file: %w", err)
+		}
+		pemData = data
+	}
+
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded private key found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA private key, got: %T", parsed)
+	}
+
+	return key, nil
+}
+
+// clientAssertionAudience returns the audience Auth0 expects in a client
+// assertion: the origin of the token endpoint with a trailing slash.
+func clientAssertionAudience(tokenURL string) string {
+	parsed, err := url.Parse(tokenURL)
+	if err != nil {
+		return tokenURL
+	}
+
+	return fmt.Sprintf("%s://%s/", parsed.Scheme, parsed.Host)
+}
+
+// signClientAssertion returns a signed client assertion JWT identifying
+// clientId to the token endpoint audience.
+func signClientAssertion(key *rsa.PrivateKey, alg string, keyId string, clientId string, audience string, now time.Time) (string, error) {
+	hash, ok := clientAssertionSigningAlgs[alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported client assertion signing algorithm %q", alg)
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate client assertion ID: %w", err)
+	}
+
+	header := map[string]string{
+		"alg": alg,
+		"typ": "JWT",
+	}
+	if keyId != "" {
+		header["kid"] = keyId
+	}
+
+	claims := map[string]interface{}{
+		"iss": clientId,
+		"sub": clientId,
+		"aud": audience,
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+		"jti": hex.EncodeToString(jti),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal client assertion header: %w", err)
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal client assertion claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+
+	var signature []byte
+	if strings.HasPrefix(alg, "PS") {
+		signature, err = rsa.SignPSS(rand.Reader, key, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// defaultWriteBatchWindow is how long client changes to a connection are
+// collected before they are written, when write_batch_window isn't set.
+const defaultWriteBatchWindow = 200 * time.Millisecond
+
+// connectionUpdateAttempts is how many read-compute-write rounds are made for
+// a connection before a concurrent change is reported as an error.
+const connectionUpdateAttempts = 3
+
+// connectionLocks serializes read-modify-write cycles per connection ID
+// across every resource of the provider process.
+type connectionLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// lock acquires the lock of connectionId, giving up when ctx is done. The
+// returned function releases it.
+func (l *connectionLocks) lock(ctx context.Context, connectionId string) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]chan struct{})
+	}
+	lock, ok := l.locks[connectionId]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		l.locks[connectionId] = lock
+	}
+	l.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		return func() { <-lock }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// connectionWriteBatch collects the client changes requested for one
+// connection during the batch window, and the outcome of writing them.
+type connectionWriteBatch struct {
+	// changes maps client IDs to their requested enabled state. When
+	// several resources ask for the same client, the last request wins.
+	changes map[string]bool
+
+	// done is closed once the batch is written; changed and errs then
+	// hold the outcome of each client's change.
+	done    chan struct{}
+	changed map[string]bool
+	errs    map[string]error
+}
+
+// clientChangeError is the error of a connection update that carried only
+// one client's change, so it can be attributed to that client.
+type clientChangeError struct {
+	err error
+}
+
+func (e *clientChangeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *clientChangeError) Unwrap() error {
+	return e.err
+}
+
+// isClientChangeError reports whether err comes from an update that carried
+// only the change of the client it was returned for.
+func isClientChangeError(err error) bool {
+	var changeErr *clientChangeError
+	return errors.As(err, &changeErr)
+}
+
+// connectionWriteQueue coalesces the client changes of a connection made by
+// many resources into a single read-modify-write cycle per batch window.
+type connectionWriteQueue struct {
+	mu      sync.Mutex
+	pending map[string]*connectionWriteBatch
+}
+
+// setConnectionClientEnabled enables or disables clientId on a connection,
+// keeping every other client. The change is queued for WriteBatchWindow so
+// changes from other resources to the same connection go out in the same
+// update, split only where a batch exceeds the per-request limit of the
+// endpoint, and the call returns once the batch is written. It reports
+// whether clientId's membership was changed, and only fails for errors that
+// apply to clientId's change.
+func (c *Auth0Client) setConnectionClientEnabled(ctx context.Context, connectionId string, clientId string, enabled bool) (bool, error) {
+	q := &c.writeQueue
+
+	q.mu.Lock()
+	if q.pending == nil {
+		q.pending = make(map[string]*connectionWriteBatch)
+	}
+	batch, ok := q.pending[connectionId]
+	if !ok {
+		batch = &connectionWriteBatch{
+			changes: make(map[string]bool),
+			done:    make(chan struct{}),
+		}
+		q.pending[connectionId] = batch
+
+		// The batch is written on behalf of every waiting resource, so it
+		// must not be aborted when the resource that opened it gives up.
+		flushCtx := context.WithoutCancel(ctx)
+		time.AfterFunc(c.WriteBatchWindow, func() {
+			q.mu.Lock()
+			delete(q.pending, connectionId)
+			q.mu.Unlock()
+
+			c.writeConnectionBatch(flushCtx, connectionId, batch)
+			close(batch.done)
+		})
+	}
+	batch.changes[clientId] = enabled
+	q.mu.Unlock()
+
+	select {
+	case <-batch.done:
+		return batch.changed[clientId], batch.errs[clientId]
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// writeConnectionBatch writes the changes of batch and records the outcome
+// of each of them. When Auth0 rejects a batch of several changes, every
+// change is written again on its own, so an invalid client only fails the
+// resources that asked for it.
+func (c *Auth0Client) writeConnectionBatch(ctx context.Context, connectionId string, batch *connectionWriteBatch) {
+	batch.changed = make(map[string]bool, len(batch.changes))
+	batch.errs = make(map[string]error, len(batch.changes))
+
+	changed, err := c.applyConnectionClientChanges(ctx, connectionId, batch.changes)
+	for clientId := range changed {
+		batch.changed[clientId] = true
+	}
+	if err == nil {
+		return
+	}
+
+	if len(batch.changes) == 1 {
+		err = &clientChangeError{err: err}
+	}
+	if len(batch.changes) == 1 || !isRejectedChange(err) {
+		for clientId := range batch.changes {
+			batch.errs[clientId] = err
+		}
+		return
+	}
+
+	tflog.Debug(ctx, "Auth0 rejected coalesced connection client changes, writing them one by one", map[string]interface{}{
+		"connection_id": connectionId,
+		"changes":       len(batch.changes),
+	})
+
+	for _, clientId := range sortedMapKeys(batch.changes) {
+		changed, err := c.applyConnectionClientChanges(ctx, connectionId, map[string]bool{clientId: batch.changes[clientId]})
+		if changed[clientId] {
+			batch.changed[clientId] = true
+		}
+		if err != nil {
+			batch.errs[clientId] = &clientChangeError{err: err}
+		}
+	}
+}
+
+// isRejectedChange reports whether err is a 4xx that may be caused by the
+// content of an update rather than by the connection or the credentials, so
+// writing its changes separately can tell which of them is at fault.
+func isRejectedChange(err error) bool {
+	status := apiErrorStatus(err)
+	if status < 400 || status >= 500 {
+		return false
+	}
+
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests:
+		return false
+	}
+
+	return true
+}
+
+// applyConnectionClientChanges writes a set of client changes to a
+// connection, keeping every other client. The read, the update (chunked by
+// updateConnectionClients) and a verifying re-read run under the connection's
+// lock; when the re-read shows that a concurrent change undid ours, the cycle
+// is repeated. It reports which clients' membership was changed.
+func (c *Auth0Client) applyConnectionClientChanges(ctx context.Context, connectionId string, changes map[string]bool) (map[string]bool, error) {
+	unlock, err := c.connectionLocks.lock(ctx, connectionId)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Whatever happens below, cached clients of the connection may be stale
+	// until the verifying re-read replaces them.
+	c.cache.invalidate(connectionClientsCacheKey(connectionId))
+
+	changed := make(map[string]bool)
+	for attempt := 1; attempt <= connectionUpdateAttempts; attempt++ {
+		currentClients, err := c.fetchConnectionClients(ctx, connectionId)
+		if err != nil {
+			return changed, err
+		}
+
+		newClients := mergeClientChanges(currentClients, changes)
+		if stringSlicesEqual(sortedUnique(currentClients), newClients) {
+			c.cache.set(connectionClientsCacheKey(connectionId), currentClients)
+			return changed, nil
+		}
+
+		if err := c.updateConnectionClients(ctx, connectionId, currentClients, newClients); err != nil {
+			return changed, err
+		}
+
+		for clientId, enabled := range changes {
+			if containsString(currentClients, clientId) != enabled {
+				changed[clientId] = true
+			}
+		}
+
+		verifiedClients, err := c.fetchConnectionClients(ctx, connectionId)
+		if err != nil {
+			return changed, err
+		}
+
+		verified := true
+		for clientId, enabled := range changes {
+			if containsString(verifiedClients, clientId) != enabled {
+				verified = false
+				break
+			}
+		}
+		if verified {
+			c.cache.set(connectionClientsCacheKey(connectionId), verifiedClients)
+			return changed, nil
+		}
+
+		tflog.Debug(ctx, "Concurrent change to Auth0 connection clients detected, retrying", map[string]interface{}{
+			"connection_id": connectionId,
+			"attempt":       attempt,
+		})
+	}
+
+	return changed, fmt.Errorf("clients of connection %s were changed concurrently %d times in a row; try again", connectionId, connectionUpdateAttempts)
+}
+
+// mergeClientChanges applies every change of changes to currentClients, in
+// client ID order, and returns the sorted result without duplicates.
+func mergeClientChanges(currentClients []string, changes map[string]bool) []string {
+	clientIds := make([]string, 0, len(changes))
+	for clientId := range changes {
+		clientIds = append(clientIds, clientId)
+	}
+	sort.Strings(clientIds)
+
+	newClients := sortedUnique(currentClients)
+	for _, clientId := range clientIds {
+		newClients = mergeEnabledClients(newClients, clientId, changes[clientId])
+	}
+
+	return newClients
+}
+
+// mergeEnabledClients returns the sorted client list of a connection after
+// enabling or disabling clientId, keeping every other client exactly once.
+// currentClients is left untouched.
+func mergeEnabledClients(currentClients []string, clientId string, enabled bool) []string {
+	newClients := []string{}
+	seen := make(map[string]bool, len(currentClients))
+
+	// Add all clients except our application, dropping duplicates
+	for _, id := range currentClients {
+		if id != clientId && !seen[id] {
+			seen[id] = true
+			newClients = append(newClients, id)
+		}
+	}
+
+	// Add our application if it should be enabled for this connection
+	if enabled {
+		newClients = append(newClients, clientId)
+	}
+
+	sort.Strings(newClients)
+	return newClients
+}
+
+// sortedCopy returns a sorted copy of values, leaving values untouched.
+func sortedCopy(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// sortedUnique returns a sorted copy of values with every duplicate dropped,
+// leaving values untouched.
+func sortedUnique(values []string) []string {
+	unique := []string{}
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
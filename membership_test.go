@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergeEnabledClients(t *testing.T) {
+	tests := []struct {
+		name           string
+		currentClients []string
+		clientId       string
+		enabled        bool
+		want           []string
+	}{
+		{"enable on empty list", nil, "app", true, []string{"app"}},
+		{"disable on empty list", []string{}, "app", false, []string{}},
+		{"enable keeps others", []string{"b", "a"}, "app", true, []string{"a", "app", "b"}},
+		{"disable keeps others", []string{"b", "app", "a"}, "app", false, []string{"a", "b"}},
+		{"already enabled", []string{"app", "a"}, "app", true, []string{"a", "app"}},
+		{"already disabled", []string{"a"}, "app", false, []string{"a"}},
+		{"duplicate application", []string{"app", "a", "app"}, "app", true, []string{"a", "app"}},
+		{"duplicate application disabled", []string{"app", "app"}, "app", false, []string{}},
+		{"duplicate other clients", []string{"a", "b", "a", "b"}, "app", true, []string{"a", "app", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]string(nil), tt.currentClients...)
+
+			got := mergeEnabledClients(tt.currentClients, tt.clientId, tt.enabled)
+
+			if !stringSlicesEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !stringSlicesEqual(tt.currentClients, input) {
+				t.Errorf("currentClients was changed to %v", tt.currentClients)
+			}
+		})
+	}
+}
+
+// checkMergeInvariants checks that only clientId's membership changed between
+// currentClients and newClients, that every other client was kept, and that
+// newClients is sorted and has no duplicates.
+func checkMergeInvariants(currentClients []string, clientId string, enabled bool, newClients []string) error {
+	if !sort.StringsAreSorted(newClients) {
+		return fmt.Errorf("result %v is not sorted", newClients)
+	}
+
+	seen := make(map[string]bool, len(newClients))
+	for _, id := range newClients {
+		if seen[id] {
+			return fmt.Errorf("result %v has duplicate %q", newClients, id)
+		}
+		seen[id] = true
+	}
+
+	if seen[clientId] != enabled {
+		return fmt.Errorf("result %v: membership of %q is %t, want %t", newClients, clientId, seen[clientId], enabled)
+	}
+
+	current := make(map[string]bool, len(currentClients))
+	for _, id := range currentClients {
+		current[id] = true
+		if id != clientId && !seen[id] {
+			return fmt.Errorf("result %v dropped %q", newClients, id)
+		}
+	}
+
+	for _, id := range newClients {
+		if id != clientId && !current[id] {
+			return fmt.Errorf("result %v added %q", newClients, id)
+		}
+	}
+
+	return nil
+}
+
+// TestMergeEnabledClientsProperties checks the merge invariants over random
+// tenants, where every connection has a random client list drawn from a small
+// pool so duplicates and already enabled applications are common.
+func TestMergeEnabledClientsProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pool := []string{"app_1", "app_2", "app_3", "app_4", "app_5"}
+
+	for tenant := 0; tenant < 200; tenant++ {
+		connections := make([][]string, rng.Intn(20))
+		for i := range connections {
+			clients := make([]string, rng.Intn(8))
+			for j := range clients {
+				clients[j] = pool[rng.Intn(len(pool))]
+			}
+			connections[i] = clients
+		}
+
+		applicationId := pool[rng.Intn(len(pool))]
+		for i, currentClients := range connections {
+			enabled := rng.Intn(2) == 0
+			input := append([]string(nil), currentClients...)
+
+			newClients := mergeEnabledClients(currentClients, applicationId, enabled)
+
+			if err := checkMergeInvariants(currentClients, applicationId, enabled, newClients); err != nil {
+				t.Fatalf("tenant %d, connection %d, %s enabled=%t on %v: %s", tenant, i, applicationId, enabled, currentClients, err)
+			}
+			if !stringSlicesEqual(currentClients, input) {
+				t.Fatalf("tenant %d, connection %d: currentClients was changed to %v", tenant, i, currentClients)
+			}
+
+			// Applying the same change again is a no-op.
+			if again := mergeEnabledClients(newClients, applicationId, enabled); !stringSlicesEqual(again, newClients) {
+				t.Fatalf("tenant %d, connection %d: merge is not idempotent: %v then %v", tenant, i, newClients, again)
+			}
+		}
+	}
+}
+
+// TestMergeClientChangesProperties checks that a batch of changes from many
+// resources sets each changed client's membership and keeps every other
+// client, whatever the order the changes were queued in.
+func TestMergeClientChangesProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	pool := []string{"app_1", "app_2", "app_3", "app_4", "app_5", "app_6"}
+
+	for round := 0; round < 500; round++ {
+		currentClients := make([]string, rng.Intn(10))
+		for i := range currentClients {
+			currentClients[i] = pool[rng.Intn(len(pool))]
+		}
+
+		changes := make(map[string]bool)
+		for i := rng.Intn(len(pool)); i > 0; i-- {
+			changes[pool[rng.Intn(len(pool))]] = rng.Intn(2) == 0
+		}
+
+		newClients := mergeClientChanges(currentClients, changes)
+
+		want := make(map[string]bool)
+		for _, id := range currentClients {
+			want[id] = true
+		}
+		for id, enabled := range changes {
+			want[id] = enabled
+		}
+
+		var wantClients []string
+		for id, enabled := range want {
+			if enabled {
+				wantClients = append(wantClients, id)
+			}
+		}
+		sort.Strings(wantClients)
+
+		if !stringSlicesEqual(newClients, wantClients) {
+			t.Fatalf("changes %v on %v: got %v, want %v", changes, currentClients, newClients, wantClients)
+		}
+	}
+}
+
+func FuzzMergeEnabledClients(f *testing.F) {
+	f.Add("", "app", true)
+	f.Add("app", "app", true)
+	f.Add("app,app", "app", false)
+	f.Add("a,b,a", "app", true)
+	f.Add("b,app,a,app", "app", false)
+
+	f.Fuzz(func(t *testing.T, clients string, clientId string, enabled bool) {
+		var currentClients []string
+		if clients != "" {
+			currentClients = strings.Split(clients, ",")
+		}
+		input := append([]string(nil), currentClients...)
+
+		newClients := mergeEnabledClients(currentClients, clientId, enabled)
+
+		if err := checkMergeInvariants(currentClients, clientId, enabled, newClients); err != nil {
+			t.Fatalf("%q enabled=%t on %q: %s", clientId, enabled, currentClients, err)
+		}
+		if !stringSlicesEqual(currentClients, input) {
+			t.Fatalf("currentClients was changed to %q", currentClients)
+		}
+	})
+}
@@ -2,9 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -25,16 +34,87 @@ type Auth0ConnectionsProvider struct {
 // Auth0ConnectionsProviderModel describes the provider data model.
 type Auth0ConnectionsProviderModel struct {
 	Domain       types.String `tfsdk:"domain"`
+	CustomDomain types.String `tfsdk:"custom_domain"`
 	ClientId     types.String `tfsdk:"client_id"`
 	ClientSecret types.String `tfsdk:"client_secret"`
+	APIToken     types.String `tfsdk:"api_token"`
+
+	ClientAssertionPrivateKey types.String `tfsdk:"client_assertion_private_key"`
+	ClientAssertionKeyId      types.String `tfsdk:"client_assertion_key_id"`
+	ClientAssertionSigningAlg types.String `tfsdk:"client_assertion_signing_alg"`
+
+	APIBaseURL       types.String `tfsdk:"api_base_url"`
+	TokenURL         types.String `tfsdk:"token_url"`
+	Audience         types.String `tfsdk:"audience"`
+	RetryMaxAttempts types.Int64  `tfsdk:"retry_max_attempts"`
+	RetryMaxWait     types.String `tfsdk:"retry_max_wait"`
+	MaxConcurrency   types.Int64  `tfsdk:"max_concurrency"`
+
+	UseLegacyEnabledClients types.Bool   `tfsdk:"use_legacy_enabled_clients"`
+	WriteBatchWindow        types.String `tfsdk:"write_batch_window"`
+	CacheTTL                types.String `tfsdk:"cache_ttl"`
+
+	CABundleFile          types.String `tfsdk:"ca_bundle_file"`
+	ProxyURL              types.String `tfsdk:"proxy_url"`
+	ClientCertificateFile types.String `tfsdk:"client_certificate_file"`
+	ClientKeyFile         types.String `tfsdk:"client_key_file"`
+	RequestTimeout        types.String `tfsdk:"request_timeout"`
+	Timeout               types.String `tfsdk:"timeout"`
 }
 
-// Auth0Client represents the Auth0 API client
+// Auth0Client represents the Auth0 API client shared by all resources and
+// data sources. See client.go for the Management API methods.
 type Auth0Client struct {
 	Domain       string
 	ClientId     string
 	ClientSecret string
 	HTTPClient   *http.Client
+
+	// APIToken is a static Management API token used instead of the client
+	// credentials exchange; APITokenExpiry holds its exp claim, if any.
+	APIToken       string
+	APITokenExpiry time.Time
+
+	// ClientAssertionKey, when set, authenticates the token request with a
+	// Private Key JWT client assertion instead of ClientSecret.
+	ClientAssertionKey        *rsa.PrivateKey
+	ClientAssertionKeyId      string
+	ClientAssertionSigningAlg string
+
+	// APIBaseURL is the Management API base URL (https://<domain>/api/v2/
+	// by default), TokenURL the token endpoint and Audience the audience
+	// requested for Management API access tokens.
+	APIBaseURL string
+	TokenURL   string
+	Audience   string
+
+	// MaxConcurrency bounds the number of Management API reads run in
+	// parallel, e.g. when reading the enabled clients of every connection.
+	MaxConcurrency int
+
+	// UseLegacyEnabledClients switches reads and writes of a connection's
+	// clients from the /connections/{id}/clients endpoints to the deprecated
+	// enabled_clients field, for tenants without the new endpoints.
+	UseLegacyEnabledClients bool
+
+	// WriteBatchWindow is how long client changes to a connection are
+	// collected from all resources before they are written in one update.
+	WriteBatchWindow time.Duration
+
+	// connectionLocks serializes updates of a connection's clients between
+	// all resources of the provider process.
+	connectionLocks connectionLocks
+	writeQueue      connectionWriteQueue
+
+	// cache holds connection reads shared by all resources and data
+	// sources; nil when caching is disabled.
+	cache *apiCache
+
+	// tokenMu guards the cached Management API access token, which is
+	// shared by every resource and data source of the provider instance.
+	tokenMu     sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
 }
 
 func (p *Auth0ConnectionsProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -46,18 +126,100 @@ func (p *Auth0ConnectionsProvider) Schema(ctx context.Context, req provider.Sche
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
 			"domain": schema.StringAttribute{
-				MarkdownDescription: "Auth0 domain (e.g., your-tenant.auth0.com)",
-				Required:            true,
+				MarkdownDescription: "Auth0 domain (e.g., your-tenant.auth0.com). When `custom_domain` is set, this must be the canonical tenant domain, which is used for the token audience and the Management API. Can also be set with the `AUTH0_DOMAIN` environment variable.",
+				Optional:            true,
+			},
+			"custom_domain": schema.StringAttribute{
+				MarkdownDescription: "Auth0 custom domain (e.g., login.example.com) used for the token endpoint. The provider verifies that `domain` is the canonical tenant domain behind it by comparing the issuers and the signing key IDs of both domains' OpenID configurations, which are read from the hosts of `api_base_url` and `token_url`.",
+				Optional:            true,
 			},
 			"client_id": schema.StringAttribute{
-				MarkdownDescription: "Auth0 Management API client ID",
-				Required:            true,
+				MarkdownDescription: "Auth0 Management API client ID. Can also be set with the `AUTH0_CLIENT_ID` environment variable.",
+				Optional:            true,
 			},
 			"client_secret": schema.StringAttribute{
-				MarkdownDescription: "Auth0 Management API client secret",
-				Required:            true,
+				MarkdownDescription: "Auth0 Management API client secret. Can also be set with the `AUTH0_CLIENT_SECRET` environment variable.",
+				Optional:            true,
 				Sensitive:           true,
 			},
+			"client_assertion_private_key": schema.StringAttribute{
+				MarkdownDescription: "PEM encoded RSA private key, or the path to a file containing it, used to sign a Private Key JWT client assertion for the token request instead of sending `client_secret`. Can also be set with the `AUTH0_CLIENT_ASSERTION_PRIVATE_KEY` environment variable.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"client_assertion_key_id": schema.StringAttribute{
+				MarkdownDescription: "Key ID (`kid`) of the credential registered for the client assertion signing key. Can also be set with the `AUTH0_CLIENT_ASSERTION_KEY_ID` environment variable.",
+				Optional:            true,
+			},
+			"client_assertion_signing_alg": schema.StringAttribute{
+				MarkdownDescription: "Algorithm used to sign the client assertion: `RS256`, `RS384` or `PS256`. Defaults to `RS256`. Can also be set with the `AUTH0_CLIENT_ASSERTION_SIGNING_ALG` environment variable.",
+				Optional:            true,
+			},
+			"api_token": schema.StringAttribute{
+				MarkdownDescription: "Static Auth0 Management API access token. When set, the client credentials exchange is skipped; conflicts with `client_id` and `client_secret`. Can also be set with the `AUTH0_API_TOKEN` environment variable.",
+				Optional:            true,
+				Sensitive:           true,
+			},
+			"api_base_url": schema.StringAttribute{
+				MarkdownDescription: "Base URL of the Auth0 Management API. Defaults to `https://<domain>/api/v2/`. Useful for private cloud deployments and local stand-ins of the Management API.",
+				Optional:            true,
+			},
+			"token_url": schema.StringAttribute{
+				MarkdownDescription: "URL of the OAuth token endpoint used to obtain Management API access tokens. Defaults to `https://<domain>/oauth/token`.",
+				Optional:            true,
+			},
+			"audience": schema.StringAttribute{
+				MarkdownDescription: "Audience requested for Management API access tokens. Defaults to `https://<domain>/api/v2/`.",
+				Optional:            true,
+			},
+			"retry_max_attempts": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Maximum number of attempts for a single Management API call that is rate limited (429) or fails with a server error (5xx). Defaults to `%d`.", defaultRetryMaxAttempts),
+				Optional:            true,
+			},
+			"retry_max_wait": schema.StringAttribute{
+				MarkdownDescription: fmt.Sprintf("Maximum total time a single Management API call may spend waiting between retries, as a duration such as `30s` or `2m`. Defaults to `%s`.", defaultRetryMaxWait),
+				Optional:            true,
+			},
+			"max_concurrency": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Maximum number of Management API reads run in parallel, e.g. when reading the enabled clients of every connection. Defaults to `%d`.", defaultMaxConcurrency),
+				Optional:            true,
+			},
+			"use_legacy_enabled_clients": schema.BoolAttribute{
+				MarkdownDescription: "Read and write a connection's clients through the deprecated `enabled_clients` field of `/api/v2/connections/{id}` instead of the `/api/v2/connections/{id}/clients` endpoints. Only needed for tenants that don't have the new endpoints yet. Defaults to `false`.",
+				Optional:            true,
+			},
+			"write_batch_window": schema.StringAttribute{
+				MarkdownDescription: fmt.Sprintf("How long changes to a connection's clients are collected from all resources before they are written in a single update, as a duration such as `500ms`. Larger windows send fewer updates on large applies. Defaults to `%s`.", defaultWriteBatchWindow),
+				Optional:            true,
+			},
+			"cache_ttl": schema.StringAttribute{
+				MarkdownDescription: "How long the connection list and each connection's clients are cached and shared between all resources and data sources, as a duration such as `10m`. By default they are cached for the whole Terraform run; set to `0s` to disable the cache. Changes made by this provider always update the cache.",
+				Optional:            true,
+			},
+			"ca_bundle_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a PEM file of additional trusted CA certificates, such as the CA of a TLS intercepting egress proxy. The certificates are added to the system roots.",
+				Optional:            true,
+			},
+			"proxy_url": schema.StringAttribute{
+				MarkdownDescription: "URL of the proxy used for all Auth0 requests. Defaults to the `HTTPS_PROXY`, `HTTP_PROXY` and `NO_PROXY` environment variables.",
+				Optional:            true,
+			},
+			"client_certificate_file": schema.StringAttribute{
+				MarkdownDescription: "Path to a PEM client certificate presented for mutual TLS. Requires `client_key_file`.",
+				Optional:            true,
+			},
+			"client_key_file": schema.StringAttribute{
+				MarkdownDescription: "Path to the PEM private key of `client_certificate_file`.",
+				Optional:            true,
+			},
+			"request_timeout": schema.StringAttribute{
+				MarkdownDescription: "Maximum time for a single HTTP attempt to connect and receive response headers, as a duration such as `30s`. Unlimited by default.",
+				Optional:            true,
+			},
+			"timeout": schema.StringAttribute{
+				MarkdownDescription: "Maximum time for a single Auth0 call including its retries, as a duration such as `5m`. Unlimited by default.",
+				Optional:            true,
+			},
 		},
 	}
 }
@@ -71,40 +233,286 @@ func (p *Auth0ConnectionsProvider) Configure(ctx context.Context, req provider.C
 		return
 	}
 
-	// Validate required attributes
-	if config.Domain.IsUnknown() || config.Domain.IsNull() {
+	// Attributes not set in the configuration fall back to the environment
+	// variables used by the official Auth0 provider.
+	domain, ok := stringValueOrEnv(config.Domain, "AUTH0_DOMAIN", path.Root("domain"), &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	if domain == "" {
 		resp.Diagnostics.AddError(
 			"Missing Auth0 Domain",
 			"The provider cannot create the Auth0 API client as there is a missing or empty value for the Auth0 domain. "+
-				"Set the domain value in the configuration and ensure the value is not empty.",
+				"Set the domain value in the configuration or use the AUTH0_DOMAIN environment variable and ensure the value is not empty.",
 		)
 		return
 	}
 
-	if config.ClientId.IsUnknown() || config.ClientId.IsNull() {
-		resp.Diagnostics.AddError(
-			"Missing Auth0 Client ID",
-			"The provider cannot create the Auth0 API client as there is a missing or empty value for the Auth0 client ID. "+
-				"Set the client_id value in the configuration and ensure the value is not empty.",
+	apiToken, ok := stringValueOrEnv(config.APIToken, "AUTH0_API_TOKEN", path.Root("api_token"), &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	// Client credentials set explicitly in the configuration win over an
+	// api_token coming from the environment, but never over a configured one.
+	clientCredentialsConfigured := !config.ClientId.IsNull() || !config.ClientSecret.IsNull() || !config.ClientAssertionPrivateKey.IsNull()
+	if !config.APIToken.IsNull() && clientCredentialsConfigured {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("api_token"),
+			"Conflicting Auth0 Credentials",
+			"The api_token value cannot be combined with client_id, client_secret or client_assertion_private_key. "+
+				"Either configure a static Management API token, or client credentials for the provider to request tokens with.",
 		)
 		return
 	}
+	if clientCredentialsConfigured {
+		apiToken = ""
+	}
+
+	var clientId, clientSecret string
+	var apiTokenExpiry time.Time
+	var clientAssertionKey *rsa.PrivateKey
+	var clientAssertionKeyId, clientAssertionSigningAlg string
+	if apiToken != "" {
+		var err error
+		apiTokenExpiry, err = jwtExpiry(apiToken)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("api_token"),
+				"Invalid Auth0 API Token",
+				fmt.Sprintf("The api_token value could not be read as a Management API access token: %s", err),
+			)
+			return
+		}
+
+		if !apiTokenExpiry.IsZero() {
+			remaining := time.Until(apiTokenExpiry)
+			if remaining <= 0 {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("api_token"),
+					"Expired Auth0 API Token",
+					fmt.Sprintf("The configured Management API token expired at %s. Request a fresh token and try again.", apiTokenExpiry.Format(time.RFC3339)),
+				)
+				return
+			}
+
+			if remaining < apiTokenExpiryWarning {
+				resp.Diagnostics.AddAttributeWarning(
+					path.Root("api_token"),
+					"Auth0 API Token Expires Soon",
+					fmt.Sprintf("The configured Management API token expires at %s, in %s. "+
+						"Operations still running at that time will fail; request a longer-lived token for large applies.", apiTokenExpiry.Format(time.RFC3339), remaining.Round(time.Second)),
+				)
+			}
+		}
+	} else {
+		clientId, ok = stringValueOrEnv(config.ClientId, "AUTH0_CLIENT_ID", path.Root("client_id"), &resp.Diagnostics)
+		if !ok {
+			return
+		}
+		if clientId == "" {
+			resp.Diagnostics.AddError(
+				"Missing Auth0 Client ID",
+				"The provider cannot create the Auth0 API client as there is a missing or empty value for the Auth0 client ID. "+
+					"Set the client_id value in the configuration or use the AUTH0_CLIENT_ID environment variable and ensure the value is not empty, or configure api_token instead.",
+			)
+			return
+		}
+
+		privateKey, ok := stringValueOrEnv(config.ClientAssertionPrivateKey, "AUTH0_CLIENT_ASSERTION_PRIVATE_KEY", path.Root("client_assertion_private_key"), &resp.Diagnostics)
+		if !ok {
+			return
+		}
+
+		if privateKey != "" {
+			if !config.ClientSecret.IsNull() {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("client_assertion_private_key"),
+					"Conflicting Auth0 Credentials",
+					"The client_assertion_private_key value cannot be combined with client_secret. "+
+						"Private Key JWT authentication replaces the client secret; remove client_secret from the configuration.",
+				)
+				return
+			}
+
+			var err error
+			clientAssertionKey, err = loadClientAssertionKey(privateKey)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("client_assertion_private_key"),
+					"Invalid Client Assertion Private Key",
+					fmt.Sprintf("The client_assertion_private_key value must be a PEM encoded RSA private key or the path to one: %s", err),
+				)
+				return
+			}
+
+			clientAssertionKeyId, ok = stringValueOrEnv(config.ClientAssertionKeyId, "AUTH0_CLIENT_ASSERTION_KEY_ID", path.Root("client_assertion_key_id"), &resp.Diagnostics)
+			if !ok {
+				return
+			}
+
+			clientAssertionSigningAlg, ok = stringValueOrEnv(config.ClientAssertionSigningAlg, "AUTH0_CLIENT_ASSERTION_SIGNING_ALG", path.Root("client_assertion_signing_alg"), &resp.Diagnostics)
+			if !ok {
+				return
+			}
+			if clientAssertionSigningAlg == "" {
+				clientAssertionSigningAlg = "RS256"
+			}
+			if _, supported := clientAssertionSigningAlgs[clientAssertionSigningAlg]; !supported {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("client_assertion_signing_alg"),
+					"Unsupported Client Assertion Signing Algorithm",
+					fmt.Sprintf("The client_assertion_signing_alg value must be one of RS256, RS384 or PS256, got: %q.", clientAssertionSigningAlg),
+				)
+				return
+			}
+		} else {
+			clientSecret, ok = stringValueOrEnv(config.ClientSecret, "AUTH0_CLIENT_SECRET", path.Root("client_secret"), &resp.Diagnostics)
+			if !ok {
+				return
+			}
+			if clientSecret == "" {
+				resp.Diagnostics.AddError(
+					"Missing Auth0 Client Secret",
+					"The provider cannot create the Auth0 API client as there is a missing or empty value for the Auth0 client secret. "+
+						"Set the client_secret value in the configuration or use the AUTH0_CLIENT_SECRET environment variable and ensure the value is not empty, or configure client_assertion_private_key or api_token instead.",
+				)
+				return
+			}
+		}
+	}
+
+	apiBaseURL, ok := configuredURL(config.APIBaseURL, path.Root("api_base_url"), fmt.Sprintf("https://%s/api/v2/", domain), &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	// Tokens are requested through the custom domain when one is set, while
+	// the audience and the Management API stay on the canonical domain.
+	tokenDomain := domain
+	customDomain := ""
+	if !config.CustomDomain.IsNull() && !config.CustomDomain.IsUnknown() && config.CustomDomain.ValueString() != "" {
+		customDomain = config.CustomDomain.ValueString()
+		tokenDomain = customDomain
+	}
+
+	tokenURL, ok := configuredURL(config.TokenURL, path.Root("token_url"), fmt.Sprintf("https://%s/oauth/token", tokenDomain), &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	audience := fmt.Sprintf("https://%s/api/v2/", domain)
+	if !config.Audience.IsNull() && !config.Audience.IsUnknown() && config.Audience.ValueString() != "" {
+		audience = config.Audience.ValueString()
+	}
+
+	retryMaxAttempts := defaultRetryMaxAttempts
+	if !config.RetryMaxAttempts.IsNull() && !config.RetryMaxAttempts.IsUnknown() {
+		retryMaxAttempts = int(config.RetryMaxAttempts.ValueInt64())
+		if retryMaxAttempts < 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("retry_max_attempts"),
+				"Invalid Retry Max Attempts",
+				fmt.Sprintf("The retry_max_attempts value must be at least 1, got: %d.", retryMaxAttempts),
+			)
+			return
+		}
+	}
+
+	maxConcurrency := defaultMaxConcurrency
+	if !config.MaxConcurrency.IsNull() && !config.MaxConcurrency.IsUnknown() {
+		maxConcurrency = int(config.MaxConcurrency.ValueInt64())
+		if maxConcurrency < 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("max_concurrency"),
+				"Invalid Max Concurrency",
+				fmt.Sprintf("The max_concurrency value must be at least 1, got: %d.", maxConcurrency),
+			)
+			return
+		}
+	}
+
+	retryMaxWait, ok := durationValue(config.RetryMaxWait, path.Root("retry_max_wait"), defaultRetryMaxWait, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	writeBatchWindow, ok := durationValue(config.WriteBatchWindow, path.Root("write_batch_window"), defaultWriteBatchWindow, &resp.Diagnostics)
+	if !ok {
+		return
+	}
 
-	if config.ClientSecret.IsUnknown() || config.ClientSecret.IsNull() {
+	cacheTTL, ok := durationValue(config.CacheTTL, path.Root("cache_ttl"), 0, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	// Without cache_ttl reads are cached for the whole run, while an explicit
+	// zero duration disables the cache.
+	var cache *apiCache
+	if config.CacheTTL.IsNull() || config.CacheTTL.IsUnknown() || cacheTTL > 0 {
+		cache = newAPICache(cacheTTL)
+	}
+
+	requestTimeout, ok := durationValue(config.RequestTimeout, path.Root("request_timeout"), 0, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	timeout, ok := durationValue(config.Timeout, path.Root("timeout"), 0, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	proxyURL, ok := configuredURL(config.ProxyURL, path.Root("proxy_url"), "", &resp.Diagnostics)
+	if !ok {
+		return
+	}
+
+	httpClient, err := newHTTPClient(httpClientSettings{
+		CABundleFile:          config.CABundleFile.ValueString(),
+		ProxyURL:              proxyURL,
+		ClientCertificateFile: config.ClientCertificateFile.ValueString(),
+		ClientKeyFile:         config.ClientKeyFile.ValueString(),
+		RequestTimeout:        requestTimeout,
+		Timeout:               timeout,
+		RetryMaxAttempts:      retryMaxAttempts,
+		RetryMaxWait:          retryMaxWait,
+	})
+	if err != nil {
 		resp.Diagnostics.AddError(
-			"Missing Auth0 Client Secret",
-			"The provider cannot create the Auth0 API client as there is a missing or empty value for the Auth0 client secret. "+
-				"Set the client_secret value in the configuration and ensure the value is not empty.",
+			"Unable to Create Auth0 HTTP Client",
+			fmt.Sprintf("The provider cannot create the HTTP client from the ca_bundle_file, proxy_url, client_certificate_file and client_key_file settings.\n\nError: %s", err),
 		)
 		return
 	}
 
 	// Create Auth0 client
 	client := &Auth0Client{
-		Domain:       config.Domain.ValueString(),
-		ClientId:     config.ClientId.ValueString(),
-		ClientSecret: config.ClientSecret.ValueString(),
-		HTTPClient:   &http.Client{},
+		Domain:         domain,
+		ClientId:       clientId,
+		ClientSecret:   clientSecret,
+		APIToken:       apiToken,
+		APITokenExpiry: apiTokenExpiry,
+
+		ClientAssertionKey:        clientAssertionKey,
+		ClientAssertionKeyId:      clientAssertionKeyId,
+		ClientAssertionSigningAlg: clientAssertionSigningAlg,
+		HTTPClient:                httpClient,
+		MaxConcurrency:            maxConcurrency,
+		UseLegacyEnabledClients:   config.UseLegacyEnabledClients.ValueBool(),
+		WriteBatchWindow:          writeBatchWindow,
+		cache:                     cache,
+		APIBaseURL:                apiBaseURL,
+		TokenURL:                  tokenURL,
+		Audience:                  audience,
+	}
+
+	if customDomain != "" {
+		verifyCustomDomain(ctx, client, customDomain, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
 	}
 
 	// Make the client available to data sources and resources
@@ -112,6 +520,153 @@ func (p *Auth0ConnectionsProvider) Configure(ctx context.Context, req provider.C
 	resp.ResourceData = client
 }
 
+// apiTokenExpiryWarning is the remaining lifetime below which a configured
+// api_token is reported as about to expire.
+const apiTokenExpiryWarning = 15 * time.Minute
+
+// stringValueOrEnv returns the configured value of a string attribute, or the
+// value of envVar when the attribute is not set. Values that are still
+// unknown can't be resolved and are reported against attributePath.
+func stringValueOrEnv(value types.String, envVar string, attributePath path.Path, diags *diag.Diagnostics) (string, bool) {
+	if value.IsUnknown() {
+		diags.AddAttributeError(
+			attributePath,
+			"Unknown Provider Attribute Value",
+			fmt.Sprintf("The provider cannot create the Auth0 API client as there is an unknown configuration value for %s. "+
+				"Either set the value statically in the configuration, or use the %s environment variable.", attributePath, envVar),
+		)
+		return "", false
+	}
+
+	if !value.IsNull() && value.ValueString() != "" {
+		return value.ValueString(), true
+	}
+
+	return os.Getenv(envVar), true
+}
+
+// verifyCustomDomain checks that the client's domain is the canonical tenant
+// domain behind customDomain. Every Auth0 host reports itself as issuer, so
+// the issuers of the Management API host and of the token endpoint host must
+// differ. Both hosts of a tenant publish its signing keys, so they must share
+// a key ID; otherwise customDomain belongs to another tenant.
+func verifyCustomDomain(ctx context.Context, client *Auth0Client, customDomain string, diags *diag.Diagnostics) {
+	if strings.EqualFold(client.Domain, customDomain) {
+		diags.AddAttributeError(
+			path.Root("custom_domain"),
+			"Custom Domain Matches Tenant Domain",
+			fmt.Sprintf("The custom_domain value %q is the same as domain. "+
+				"Set domain to the canonical tenant domain (e.g. your-tenant.eu.auth0.com), or remove custom_domain.", customDomain),
+		)
+		return
+	}
+
+	tenantConfiguration, err := client.getOpenIDConfiguration(ctx, client.APIBaseURL)
+	var tenantKeyIds []string
+	if err == nil {
+		tenantKeyIds, err = client.getSigningKeyIDs(ctx, tenantConfiguration.JWKSURI)
+	}
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("domain"),
+			"Unable to Verify Auth0 Tenant Domain",
+			fmt.Sprintf("The provider could not read the OpenID configuration of the tenant domain %q to verify it against the custom domain %q. "+
+				"Ensure the domain value is the canonical tenant domain (e.g. your-tenant.eu.auth0.com).\n\nError: %s", client.Domain, customDomain, err),
+		)
+		return
+	}
+
+	customConfiguration, err := client.getOpenIDConfiguration(ctx, client.TokenURL)
+	var customKeyIds []string
+	if err == nil {
+		customKeyIds, err = client.getSigningKeyIDs(ctx, customConfiguration.JWKSURI)
+	}
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("custom_domain"),
+			"Unable to Verify Auth0 Custom Domain",
+			fmt.Sprintf("The provider could not read the OpenID configuration of the custom domain %q.\n\nError: %s", customDomain, err),
+		)
+		return
+	}
+
+	tenantIssuer := tenantConfiguration.Issuer
+	if issuerHost(tenantIssuer) == issuerHost(customConfiguration.Issuer) {
+		diags.AddAttributeError(
+			path.Root("domain"),
+			"Auth0 Domain Is Not the Canonical Tenant Domain",
+			fmt.Sprintf("The domain %q and the custom domain %q both report the issuer %q, so domain is not the canonical tenant domain. "+
+				"When custom_domain is set, domain must be the canonical tenant domain used for the Management API audience (e.g. your-tenant.eu.auth0.com).", client.Domain, customDomain, tenantIssuer),
+		)
+		return
+	}
+
+	for _, keyId := range customKeyIds {
+		if containsString(tenantKeyIds, keyId) {
+			return
+		}
+	}
+
+	diags.AddAttributeError(
+		path.Root("custom_domain"),
+		"Custom Domain Belongs to Another Tenant",
+		fmt.Sprintf("The custom domain %q publishes none of the signing keys of the tenant domain %q, so it is a custom domain of another tenant. "+
+			"Set custom_domain to a custom domain of the tenant configured in domain.", customDomain, client.Domain),
+	)
+}
+
+// issuerHost returns the lower-cased host of an OpenID issuer URL.
+func issuerHost(issuer string) string {
+	parsed, err := url.Parse(issuer)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Host)
+}
+
+// durationValue returns the configured value of an optional duration
+// attribute, or defaultValue when it is not set. Invalid and negative
+// durations are reported against attributePath.
+func durationValue(value types.String, attributePath path.Path, defaultValue time.Duration, diags *diag.Diagnostics) (time.Duration, bool) {
+	if value.IsNull() || value.IsUnknown() || value.ValueString() == "" {
+		return defaultValue, true
+	}
+
+	duration, err := time.ParseDuration(value.ValueString())
+	if err != nil || duration < 0 {
+		diags.AddAttributeError(
+			attributePath,
+			"Invalid Duration",
+			fmt.Sprintf("The %s value must be a non-negative duration such as \"30s\" or \"2m\", got: %q.", attributePath, value.ValueString()),
+		)
+		return 0, false
+	}
+
+	return duration, true
+}
+
+// configuredURL returns the configured value of an optional URL attribute, or
+// defaultURL when it is not set. Only absolute http and https URLs are
+// accepted; anything else is reported against attributePath.
+func configuredURL(value types.String, attributePath path.Path, defaultURL string, diags *diag.Diagnostics) (string, bool) {
+	if value.IsNull() || value.IsUnknown() || value.ValueString() == "" {
+		return defaultURL, true
+	}
+
+	parsed, err := url.Parse(value.ValueString())
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		diags.AddAttributeError(
+			attributePath,
+			"Invalid URL",
+			fmt.Sprintf("The %s value must be an absolute http or https URL, got: %q.", attributePath, value.ValueString()),
+		)
+		return "", false
+	}
+
+	return value.ValueString(), true
+}
+
 func (p *Auth0ConnectionsProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewApplicationConnectionsResource,
@@ -4,22 +4,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"sort"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
 var _ resource.Resource = &ApplicationConnectionsResource{}
 var _ resource.ResourceWithImportState = &ApplicationConnectionsResource{}
+var _ resource.ResourceWithUpgradeState = &ApplicationConnectionsResource{}
+var _ resource.ResourceWithModifyPlan = &ApplicationConnectionsResource{}
+var _ resource.ResourceWithValidateConfig = &ApplicationConnectionsResource{}
+
+// The modes of managing an application's connections.
+const (
+	// connectionsModeAuthoritative disables the application on every
+	// connection that isn't listed.
+	connectionsModeAuthoritative = "authoritative"
+
+	// connectionsModeAdditive only enables the application on the listed
+	// connections, and only disables it where the resource enabled it.
+	connectionsModeAdditive = "additive"
+)
 
 // ApplicationConnectionsResource defines the resource implementation.
 type ApplicationConnectionsResource struct {
@@ -28,16 +45,21 @@ type ApplicationConnectionsResource struct {
 
 // ApplicationConnectionsResourceModel describes the resource data model.
 type ApplicationConnectionsResourceModel struct {
-	Id                    types.String `tfsdk:"id"`
-	ApplicationId         types.String `tfsdk:"application_id"`
-	EnabledConnectionIds  types.List   `tfsdk:"enabled_connection_ids"`
-	ManagedConnectionIds  types.List   `tfsdk:"managed_connection_ids"`
+	Id                   types.String `tfsdk:"id"`
+	ApplicationId        types.String `tfsdk:"application_id"`
+	Mode                 types.String `tfsdk:"mode"`
+	EnabledConnectionIds types.Set    `tfsdk:"enabled_connection_ids"`
+	ManagedConnectionIds types.Set    `tfsdk:"managed_connection_ids"`
+	ConnectionsToEnable  types.Map    `tfsdk:"connections_to_enable"`
+	ConnectionsToDisable types.Map    `tfsdk:"connections_to_disable"`
+
+	Scope *ApplicationConnectionsScopeModel `tfsdk:"scope"`
 }
 
 // Auth0 Connection Client data structure
 type Auth0ConnectionClient struct {
-	ConnectionId    string   `json:"connection_id"`
-	EnabledClients  []string `json:"enabled_clients"`
+	ConnectionId   string   `json:"connection_id"`
+	EnabledClients []string `json:"enabled_clients"`
 }
 
 func NewApplicationConnectionsResource() resource.Resource {
@@ -50,11 +72,12 @@ func (r *ApplicationConnectionsResource) Metadata(ctx context.Context, req resou
 
 func (r *ApplicationConnectionsResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Manages Auth0 connection associations for a specific application. This resource ensures that the application is enabled for specified connections and disabled for all others, while preserving other applications' access.",
+		Version:             1,
+		MarkdownDescription: "Manages Auth0 connection associations for a specific application. This resource ensures that the application is enabled for specified connections and, unless `mode` is `additive`, disabled for all others, while preserving other applications' access. With a `scope`, only the connections in scope are managed, so several resources can share an application.",
 
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
-				MarkdownDescription: "Resource identifier",
+				MarkdownDescription: "Resource identifier: the application ID, followed by the scope as a query string when `scope` is set, e.g. `abc123?strategies=github&strategies=google-oauth2`",
 				Computed:            true,
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.UseStateForUnknown(),
@@ -67,17 +90,56 @@ func (r *ApplicationConnectionsResource) Schema(ctx context.Context, req resourc
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
-			"enabled_connection_ids": schema.ListAttribute{
-				MarkdownDescription: "List of connection IDs that should be enabled for this application",
+			"mode": schema.StringAttribute{
+				MarkdownDescription: "How the application's connections are managed: `authoritative` (default) disables the application on every connection that isn't listed; `additive` only enables it on the listed connections, and only disables it on connections this resource enabled, once they are no longer listed or the resource is destroyed.",
+				Optional:            true,
+				Computed:            true,
+				Default:             stringdefault.StaticString(connectionsModeAuthoritative),
+			},
+			"enabled_connection_ids": schema.SetAttribute{
+				MarkdownDescription: "Set of connection IDs that should be enabled for this application. Every ID must be a connection of the tenant, and within `scope` when it is set; unknown IDs fail the plan, or the apply when a connection is deleted in between, instead of being ignored. Refreshed from the tenant, so connections enabled or disabled outside Terraform show up as a diff.",
 				ElementType:         types.StringType,
 				Required:            true,
 			},
-			"managed_connection_ids": schema.ListAttribute{
-				MarkdownDescription: "List of all connection IDs that were managed by this resource (read-only)",
+			"managed_connection_ids": schema.SetAttribute{
+				MarkdownDescription: "Set of all connection IDs that were managed by this resource (read-only)",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"connections_to_enable": schema.MapAttribute{
+				MarkdownDescription: "Connections the plan enables for the application, as a map of connection IDs to names. Computed from the tenant at plan time, kept after the apply and cleared by the next refresh.",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+			"connections_to_disable": schema.MapAttribute{
+				MarkdownDescription: "Connections the plan disables for the application, as a map of connection IDs to names. Computed from the tenant at plan time, kept after the apply and cleared by the next refresh.",
 				ElementType:         types.StringType,
 				Computed:            true,
 			},
 		},
+
+		Blocks: map[string]schema.Block{
+			"scope": schema.SingleNestedBlock{
+				MarkdownDescription: "Limits the resource to a subset of the tenant's connections. A connection is in scope when it matches every criterion that is set. Connections out of scope are neither enabled nor disabled, and leave the scope unchanged when it is narrowed.",
+				Attributes: map[string]schema.Attribute{
+					"strategies": schema.SetAttribute{
+						MarkdownDescription: "Only manage connections with one of these strategies (e.g., `google-oauth2`, `samlp`)",
+						ElementType:         types.StringType,
+						Optional:            true,
+					},
+					"connection_ids": schema.SetAttribute{
+						MarkdownDescription: "Only manage these connections",
+						ElementType:         types.StringType,
+						Optional:            true,
+					},
+					"name_patterns": schema.SetAttribute{
+						MarkdownDescription: "Only manage connections whose name matches one of these shell patterns (e.g., `corp-*`), as understood by Go's `path.Match`",
+						ElementType:         types.StringType,
+						Optional:            true,
+					},
+				},
+			},
+		},
 	}
 }
 
@@ -111,52 +173,17 @@ func (r *ApplicationConnectionsResource) Create(ctx context.Context, req resourc
 		return
 	}
 
-	// Get access token
-	accessToken, err := r.getAccessToken(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get Auth0 access token",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
-	}
-
-	// Get all connections
-	allConnections, err := r.fetchAllConnections(ctx, accessToken)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to fetch Auth0 connections",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
-	}
-
-	// Extract enabled connection IDs from plan
-	var enabledConnectionIds []string
-	resp.Diagnostics.Append(data.EnabledConnectionIds.ElementsAs(ctx, &enabledConnectionIds, false)...)
-	if resp.Diagnostics.HasError() {
-		return
-	}
-
 	// Apply the desired state
-	managedConnections, err := r.applyConnectionState(ctx, accessToken, allConnections, data.ApplicationId.ValueString(), enabledConnectionIds)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to apply connection state",
-			fmt.Sprintf("Error: %s", err),
-		)
+	addedConnections := r.applyPlan(ctx, &data, nil, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	// Set computed values
-	data.Id = types.StringValue(data.ApplicationId.ValueString())
-	
-	managedConnectionsList, diags := types.ListValueFrom(ctx, types.StringType, managedConnections)
-	resp.Diagnostics.Append(diags...)
-	if resp.Diagnostics.HasError() {
-		return
-	}
-	data.ManagedConnectionIds = managedConnectionsList
+	scope, _ := scopeFromModel(data.Scope)
+	data.Id = types.StringValue(scope.resourceId(data.ApplicationId.ValueString()))
+
+	setAddedConnections(ctx, resp.Private, addedConnections, &resp.Diagnostics)
 
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -172,92 +199,78 @@ func (r *ApplicationConnectionsResource) Read(ctx context.Context, req resource.
 		return
 	}
 
-	// Get access token
-	accessToken, err := r.getAccessToken(ctx)
+	// Get current state of connections for this application
+	scope, _ := scopeFromModel(data.Scope)
+	currentState, err := r.getCurrentConnectionState(ctx, data.ApplicationId.ValueString(), scope)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get Auth0 access token",
-			fmt.Sprintf("Error: %s", err),
-		)
+		addAPIErrorDiagnostic(&resp.Diagnostics, "Failed to get current connection state", err)
 		return
 	}
 
-	// Get current state of connections for this application
-	currentState, err := r.getCurrentConnectionState(ctx, accessToken, data.ApplicationId.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get current connection state",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
+	// Imported resources start out authoritative.
+	if data.Mode.IsNull() {
+		data.Mode = types.StringValue(connectionsModeAuthoritative)
 	}
 
-	// Update managed connection IDs
-	managedConnectionsList, diags := types.ListValueFrom(ctx, types.StringType, currentState)
+	// Report the live memberships, so connections enabled or disabled
+	// outside Terraform show up as a diff against the configuration. In
+	// additive mode only the listed connections are of interest, and the
+	// resource only manages the connections it added.
+	enabledConnections := currentState
+	managedConnections := currentState
+	if data.Mode.ValueString() == connectionsModeAdditive {
+		var listedConnections []string
+		resp.Diagnostics.Append(data.EnabledConnectionIds.ElementsAs(ctx, &listedConnections, false)...)
+		addedConnections := getAddedConnections(ctx, req.Private, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		enabledConnections = intersectStrings(listedConnections, currentState)
+		managedConnections = intersectStrings(addedConnections, currentState)
+	}
+
+	enabledConnectionsSet, diags := types.SetValueFrom(ctx, types.StringType, nonNilStrings(enabledConnections))
+	resp.Diagnostics.Append(diags...)
+	managedConnectionsSet, diags := types.SetValueFrom(ctx, types.StringType, nonNilStrings(managedConnections))
 	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	data.ManagedConnectionIds = managedConnectionsList
+	data.EnabledConnectionIds = enabledConnectionsSet
+	data.ManagedConnectionIds = managedConnectionsSet
+
+	// Nothing is pending after a refresh; the next plan computes it again.
+	data.ConnectionsToEnable = types.MapValueMust(types.StringType, map[string]attr.Value{})
+	data.ConnectionsToDisable = types.MapValueMust(types.StringType, map[string]attr.Value{})
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *ApplicationConnectionsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data ApplicationConnectionsResourceModel
+	var data, priorData ApplicationConnectionsResourceModel
 
-	// Read Terraform plan data into the model
+	// Read Terraform plan and prior state data into the models
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &priorData)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	// Get access token
-	accessToken, err := r.getAccessToken(ctx)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get Auth0 access token",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
-	}
-
-	// Get all connections
-	allConnections, err := r.fetchAllConnections(ctx, accessToken)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to fetch Auth0 connections",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
-	}
-
-	// Extract enabled connection IDs from plan
-	var enabledConnectionIds []string
-	resp.Diagnostics.Append(data.EnabledConnectionIds.ElementsAs(ctx, &enabledConnectionIds, false)...)
+	addedConnections := r.priorAddedConnections(ctx, data, priorData, req.Private, &resp.Diagnostics)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	// Apply the desired state
-	managedConnections, err := r.applyConnectionState(ctx, accessToken, allConnections, data.ApplicationId.ValueString(), enabledConnectionIds)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to apply connection state",
-			fmt.Sprintf("Error: %s", err),
-		)
-		return
-	}
-
-	// Set computed values
-	managedConnectionsList, diags := types.ListValueFrom(ctx, types.StringType, managedConnections)
-	resp.Diagnostics.Append(diags...)
+	addedConnections = r.applyPlan(ctx, &data, addedConnections, &resp.Diagnostics)
 	if resp.Diagnostics.HasError() {
 		return
 	}
-	data.ManagedConnectionIds = managedConnectionsList
+
+	setAddedConnections(ctx, resp.Private, addedConnections, &resp.Diagnostics)
 
 	// Save updated data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
@@ -273,135 +286,356 @@ func (r *ApplicationConnectionsResource) Delete(ctx context.Context, req resourc
 		return
 	}
 
-	// Get access token
-	accessToken, err := r.getAccessToken(ctx)
+	var addedConnections []string
+	if data.Mode.ValueString() == connectionsModeAdditive {
+		addedConnections = getAddedConnections(ctx, req.Private, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// Get all connections in scope
+	scope, _ := scopeFromModel(data.Scope)
+	allConnections, err := r.fetchAllConnections(ctx, scope)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to get Auth0 access token",
-			fmt.Sprintf("Error: %s", err),
-		)
+		addAPIErrorDiagnostic(&resp.Diagnostics, "Failed to fetch Auth0 connections", err)
 		return
 	}
 
-	// Get all connections
-	allConnections, err := r.fetchAllConnections(ctx, accessToken)
+	// Disable this application from all connections, or in additive mode
+	// from the connections this resource added (cleanup)
+	changes := connectionChanges(data.Mode.ValueString(), allConnections, nil, addedConnections)
+	_, err = r.applyConnectionState(ctx, allConnections, data.ApplicationId.ValueString(), changes)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to fetch Auth0 connections",
-			fmt.Sprintf("Error: %s", err),
-		)
+		addAPIErrorDiagnostic(&resp.Diagnostics, "Failed to cleanup connection state", err)
 		return
 	}
+}
 
-	// Disable this application from all connections (cleanup)
-	_, err = r.applyConnectionState(ctx, accessToken, allConnections, data.ApplicationId.ValueString(), []string{})
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to cleanup connection state",
-			fmt.Sprintf("Error: %s", err),
+func (r *ApplicationConnectionsResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data ApplicationConnectionsResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !data.Mode.IsNull() && !data.Mode.IsUnknown() &&
+		data.Mode.ValueString() != connectionsModeAuthoritative && data.Mode.ValueString() != connectionsModeAdditive {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("mode"),
+			"Invalid Mode",
+			fmt.Sprintf("The mode must be %q or %q, got: %q.", connectionsModeAuthoritative, connectionsModeAdditive, data.Mode.ValueString()),
 		)
+	}
+
+	if data.Scope == nil {
 		return
 	}
-}
 
-func (r *ApplicationConnectionsResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("application_id"), req, resp)
+	scopeSets := map[string]types.Set{
+		"strategies":     data.Scope.Strategies,
+		"connection_ids": data.Scope.ConnectionIds,
+		"name_patterns":  data.Scope.NamePatterns,
+	}
+	for name, set := range scopeSets {
+		if !set.IsNull() && !set.IsUnknown() && len(set.Elements()) == 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("scope").AtName(name),
+				"Empty Scope",
+				fmt.Sprintf("The scope's %s must not be empty, as no connection would be in scope. Remove it to not filter on it.", name),
+			)
+		}
+	}
+
+	if namePatterns, known := knownStrings(data.Scope.NamePatterns); known {
+		for _, pattern := range namePatterns {
+			if err := validateNamePattern(pattern); err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("scope").AtName("name_patterns"),
+					"Invalid Name Pattern",
+					fmt.Sprintf("The name pattern %q is not a valid shell pattern: %s.", pattern, err),
+				)
+			}
+		}
+	}
 }
 
-// Helper methods
+// ModifyPlan computes which connections the plan enables and disables for the
+// application from the live tenant, and warns when the application loses
+// connections, since that can cut off logins.
+func (r *ApplicationConnectionsResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Nothing to preview when the resource is destroyed.
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var data, priorData ApplicationConnectionsResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.Get(ctx, &priorData)...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.ConnectionsToEnable = types.MapUnknown(types.StringType)
+	data.ConnectionsToDisable = types.MapUnknown(types.StringType)
+
+	// The ID follows from the application and the scope.
+	scope, scopeKnown := scopeFromModel(data.Scope)
+	if data.ApplicationId.IsUnknown() || !scopeKnown {
+		data.Id = types.StringUnknown()
+	} else {
+		data.Id = types.StringValue(scope.resourceId(data.ApplicationId.ValueString()))
+	}
 
-func (r *ApplicationConnectionsResource) getAccessToken(ctx context.Context) (string, error) {
-	// Reuse the same token logic from data source
-	tokenURL := fmt.Sprintf("https://%s/oauth/token", r.client.Domain)
+	// The preview needs the provider and every input to be known.
+	enabledConnectionIds, known := knownStrings(data.EnabledConnectionIds)
+	if r.client == nil || data.ApplicationId.IsUnknown() || data.Mode.IsUnknown() || !scopeKnown || !known {
+		setPlannedState(ctx, req.State, &resp.Plan, &data, &resp.Diagnostics)
+		return
+	}
+	applicationId := data.ApplicationId.ValueString()
 
-	data := fmt.Sprintf(
-		"grant_type=client_credentials&client_id=%s&client_secret=%s&audience=https://%s/api/v2/",
-		r.client.ClientId,
-		r.client.ClientSecret,
-		r.client.Domain,
-	)
+	// A replacement creates a new instance, which hasn't added connections
+	// yet. Attribute plan modifiers don't report RequiresReplace to
+	// ModifyPlan, so a changed application_id is checked as well.
+	replacing := len(resp.RequiresReplace) > 0 || !data.ApplicationId.Equal(priorData.ApplicationId)
+
+	var addedConnections []string
+	if !req.State.Raw.IsNull() && !replacing {
+		addedConnections = r.priorAddedConnections(ctx, data, priorData, req.Private, &resp.Diagnostics)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data))
+	connections, err := r.listAllConnections(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to create token request: %w", err)
+		addAPIErrorDiagnostic(&resp.Diagnostics, "Failed to fetch Auth0 connections", err)
+		return
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	connectionNames := make(map[string]string, len(connections))
+	connectionIds := make([]string, 0, len(connections))
+	outOfScope := make(map[string]bool)
+	for _, conn := range connections {
+		connectionNames[conn.Id] = conn.Name
+		if scope.contains(conn) {
+			connectionIds = append(connectionIds, conn.Id)
+		} else {
+			outOfScope[conn.Id] = true
+		}
+	}
 
-	resp, err := r.client.HTTPClient.Do(req)
+	connectionClients, err := r.getAllConnectionClients(ctx, connectionIds)
 	if err != nil {
-		return "", fmt.Errorf("failed to make token request: %w", err)
+		addAPIErrorDiagnostic(&resp.Diagnostics, "Failed to get current connection state", err)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+	for _, connId := range enabledConnectionIds {
+		if outOfScope[connId] {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("enabled_connection_ids"),
+				"Connection Outside Scope",
+				fmt.Sprintf("The connection %s (%s) is outside the scope of this resource. Widen the scope or remove it from enabled_connection_ids.", connectionNames[connId], connId),
+			)
+		} else if _, exists := connectionClients[connId]; !exists {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("enabled_connection_ids"),
+				"Unknown Auth0 Connection",
+				fmt.Sprintf("The connection %s does not exist in the tenant. Check enabled_connection_ids for typos.", connId),
+			)
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   int    `json:"expires_in"`
+	changes := connectionChanges(data.Mode.ValueString(), connectionIds, enabledConnectionIds, addedConnections)
+
+	toEnable := make(map[string]attr.Value)
+	toDisable := make(map[string]attr.Value)
+	var lost []string
+	for _, connectionId := range connectionIds {
+		clients, exists := connectionClients[connectionId]
+		if !exists {
+			continue // The connection was deleted since it was listed
+		}
+
+		wantEnabled, managed := changes[connectionId]
+		if !managed {
+			continue
+		}
+
+		switch enabled := containsString(clients, applicationId); {
+		case !enabled && wantEnabled:
+			toEnable[connectionId] = types.StringValue(connectionNames[connectionId])
+		case enabled && !wantEnabled:
+			toDisable[connectionId] = types.StringValue(connectionNames[connectionId])
+			lost = append(lost, fmt.Sprintf("%s (%s)", connectionNames[connectionId], connectionId))
+		}
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
+	data.ConnectionsToEnable = types.MapValueMust(types.StringType, toEnable)
+	data.ConnectionsToDisable = types.MapValueMust(types.StringType, toDisable)
+
+	// With nothing pending, keep the previews of the last apply, which only
+	// a refresh clears, so plans without a refresh don't show a diff.
+	if len(toEnable) == 0 && len(toDisable) == 0 && !req.State.Raw.IsNull() {
+		if !priorData.ConnectionsToEnable.IsNull() && !priorData.ConnectionsToDisable.IsNull() {
+			data.ConnectionsToEnable = priorData.ConnectionsToEnable
+			data.ConnectionsToDisable = priorData.ConnectionsToDisable
+		}
 	}
 
-	return tokenResp.AccessToken, nil
-}
+	if len(lost) > 0 {
+		resp.Diagnostics.AddAttributeWarning(
+			path.Root("enabled_connection_ids"),
+			"Application Will Lose Connections",
+			fmt.Sprintf("Applying this plan disables application %s on %d connection(s) it is currently enabled for: %s. "+
+				"Users can no longer log in to the application through them. Check enabled_connection_ids if this is not intended.",
+				applicationId, len(lost), strings.Join(lost, ", ")),
+		)
+	}
 
-func (r *ApplicationConnectionsResource) fetchAllConnections(ctx context.Context, accessToken string) ([]string, error) {
-	connectionsURL := fmt.Sprintf("https://%s/api/v2/connections", r.client.Domain)
+	setPlannedState(ctx, req.State, &resp.Plan, &data, &resp.Diagnostics)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", connectionsURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create connections request: %w", err)
+// setPlannedState stores data as the plan. An apply sets
+// managed_connection_ids to the connections it changed, so it is only known
+// in advance when the plan matches the prior state and nothing is applied.
+func setPlannedState(ctx context.Context, state tfsdk.State, plan *tfsdk.Plan, data *ApplicationConnectionsResourceModel, diags *diag.Diagnostics) {
+	diags.Append(plan.Set(ctx, data)...)
+	if diags.HasError() || state.Raw.IsNull() || plan.Raw.Equal(state.Raw) {
+		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
+	data.ManagedConnectionIds = types.SetUnknown(types.StringType)
+	diags.Append(plan.Set(ctx, data)...)
+}
 
-	resp, err := r.client.HTTPClient.Do(req)
+func (r *ApplicationConnectionsResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	applicationId, scope, err := parseResourceId(req.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make connections request: %w", err)
+		resp.Diagnostics.AddError(
+			"Invalid Import ID",
+			fmt.Sprintf("Expected an application ID, optionally followed by a scope such as ?strategies=github&name_patterns=corp-*: %s.", err),
+		)
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("connections request failed with status %d: %s", resp.StatusCode, string(body))
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), scope.resourceId(applicationId))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("application_id"), applicationId)...)
+	if scopeModel := scope.model(ctx, &resp.Diagnostics); scopeModel != nil {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("scope"), scopeModel)...)
 	}
+}
+
+// applicationConnectionsResourceModelV0 is the state of schema version 0,
+// where the connection IDs were lists.
+type applicationConnectionsResourceModelV0 struct {
+	Id                   types.String `tfsdk:"id"`
+	ApplicationId        types.String `tfsdk:"application_id"`
+	EnabledConnectionIds types.List   `tfsdk:"enabled_connection_ids"`
+	ManagedConnectionIds types.List   `tfsdk:"managed_connection_ids"`
+}
 
-	var connections []Auth0Connection
-	if err := json.NewDecoder(resp.Body).Decode(&connections); err != nil {
-		return nil, fmt.Errorf("failed to decode connections response: %w", err)
+func (r *ApplicationConnectionsResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return map[int64]resource.StateUpgrader{
+		// Version 1 turned the connection ID lists into sets.
+		0: {
+			PriorSchema: &schema.Schema{
+				Attributes: map[string]schema.Attribute{
+					"id": schema.StringAttribute{
+						Computed: true,
+					},
+					"application_id": schema.StringAttribute{
+						Required: true,
+					},
+					"enabled_connection_ids": schema.ListAttribute{
+						ElementType: types.StringType,
+						Required:    true,
+					},
+					"managed_connection_ids": schema.ListAttribute{
+						ElementType: types.StringType,
+						Computed:    true,
+					},
+				},
+			},
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				var priorData applicationConnectionsResourceModelV0
+
+				resp.Diagnostics.Append(req.State.Get(ctx, &priorData)...)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+
+				data := ApplicationConnectionsResourceModel{
+					Id:                   priorData.Id,
+					ApplicationId:        priorData.ApplicationId,
+					Mode:                 types.StringValue(connectionsModeAuthoritative),
+					ConnectionsToEnable:  types.MapValueMust(types.StringType, map[string]attr.Value{}),
+					ConnectionsToDisable: types.MapValueMust(types.StringType, map[string]attr.Value{}),
+				}
+				data.EnabledConnectionIds = upgradeListToSet(ctx, priorData.EnabledConnectionIds, &resp.Diagnostics)
+				data.ManagedConnectionIds = upgradeListToSet(ctx, priorData.ManagedConnectionIds, &resp.Diagnostics)
+				if resp.Diagnostics.HasError() {
+					return
+				}
+
+				resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+			},
+		},
+	}
+}
+
+// Helper methods
+
+// listAllConnections lists the IDs, names and strategies of every connection
+// of the tenant. Plans and applies of every resource share the listing
+// through the provider cache, whatever their scope.
+func (r *ApplicationConnectionsResource) listAllConnections(ctx context.Context) ([]Auth0Connection, error) {
+	return r.client.listConnections(ctx, connectionListOptions{
+		Fields: []string{"id", "name", "strategy"},
+	})
+}
+
+// fetchAllConnections returns the IDs of the tenant's connections in scope.
+func (r *ApplicationConnectionsResource) fetchAllConnections(ctx context.Context, scope connectionScope) ([]string, error) {
+	connections, err := r.listAllConnections(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	var connectionIds []string
 	for _, conn := range connections {
-		connectionIds = append(connectionIds, conn.Id)
+		if scope.contains(conn) {
+			connectionIds = append(connectionIds, conn.Id)
+		}
 	}
 
 	return connectionIds, nil
 }
 
-func (r *ApplicationConnectionsResource) getCurrentConnectionState(ctx context.Context, accessToken string, applicationId string) ([]string, error) {
-	// Get all connections that currently have this application enabled
-	connections, err := r.fetchAllConnections(ctx, accessToken)
+func (r *ApplicationConnectionsResource) getCurrentConnectionState(ctx context.Context, applicationId string, scope connectionScope) ([]string, error) {
+	// Get all connections in scope that currently have this application enabled
+	connections, err := r.fetchAllConnections(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	connectionClients, err := r.getAllConnectionClients(ctx, connections)
 	if err != nil {
 		return nil, err
 	}
 
 	var enabledConnections []string
 	for _, connectionId := range connections {
-		clients, err := r.getConnectionClients(ctx, accessToken, connectionId)
-		if err != nil {
-			continue // Skip if we can't get clients for this connection
-		}
-
-		for _, clientId := range clients {
+		for _, clientId := range connectionClients[connectionId] {
 			if clientId == applicationId {
 				enabledConnections = append(enabledConnections, connectionId)
 				break
@@ -412,80 +646,195 @@ func (r *ApplicationConnectionsResource) getCurrentConnectionState(ctx context.C
 	return enabledConnections, nil
 }
 
-func (r *ApplicationConnectionsResource) getConnectionClients(ctx context.Context, accessToken string, connectionId string) ([]string, error) {
-	url := fmt.Sprintf("https://%s/api/v2/connections/%s", r.client.Domain, connectionId)
+// getAllConnectionClients fetches the enabled clients of every connection in
+// parallel, bounded by the provider's max_concurrency, keyed by connection
+// ID. Connections deleted since they were listed are left out; any other
+// failure cancels the remaining fetches.
+func (r *ApplicationConnectionsResource) getAllConnectionClients(ctx context.Context, connectionIds []string) (map[string][]string, error) {
+	connectionClients := make([][]string, len(connectionIds))
+	deleted := make([]bool, len(connectionIds))
+
+	err := forEachConcurrently(ctx, len(connectionIds), r.client.MaxConcurrency, func(ctx context.Context, i int) error {
+		clients, err := r.client.getConnectionClients(ctx, connectionIds[i])
+		if isNotFound(err) {
+			deleted[i] = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read connection %s: %w", connectionIds[i], err)
+		}
+
+		connectionClients[i] = clients
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string, len(connectionIds))
+	for i, connectionId := range connectionIds {
+		if !deleted[i] {
+			result[connectionId] = connectionClients[i]
+		}
+	}
+
+	return result, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// applyPlan applies the plan in data and sets its computed attributes.
+// addedConnections are the connections an additive resource added before;
+// the connections it has added afterwards are returned. Authoritative
+// resources don't track them and get nil.
+func (r *ApplicationConnectionsResource) applyPlan(ctx context.Context, data *ApplicationConnectionsResourceModel, addedConnections []string, diags *diag.Diagnostics) []string {
+	// Get all connections in scope
+	scope, _ := scopeFromModel(data.Scope)
+	allConnections, err := r.fetchAllConnections(ctx, scope)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection request: %w", err)
+		addAPIErrorDiagnostic(diags, "Failed to fetch Auth0 connections", err)
+		return nil
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
+	// Extract enabled connection IDs from plan
+	var enabledConnectionIds []string
+	diags.Append(data.EnabledConnectionIds.ElementsAs(ctx, &enabledConnectionIds, false)...)
+	if diags.HasError() {
+		return nil
+	}
 
-	resp, err := r.client.HTTPClient.Do(req)
+	changes := connectionChanges(data.Mode.ValueString(), allConnections, enabledConnectionIds, addedConnections)
+	managedConnections, err := r.applyConnectionState(ctx, allConnections, data.ApplicationId.ValueString(), changes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make connection request: %w", err)
+		addAPIErrorDiagnostic(diags, "Failed to apply connection state", err)
+		return nil
+	}
+
+	managedConnectionsSet, setDiags := types.SetValueFrom(ctx, types.StringType, managedConnections)
+	diags.Append(setDiags...)
+	if diags.HasError() {
+		return nil
+	}
+	data.ManagedConnectionIds = managedConnectionsSet
+	data.resolvePendingConnections()
+
+	if data.Mode.ValueString() != connectionsModeAdditive {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return []string{}, nil // Return empty if connection doesn't exist or no access
+	// Connections still listed stay added; the ones no longer listed were
+	// disabled above.
+	stillAdded := intersectStrings(addedConnections, enabledConnectionIds)
+	for _, connectionId := range managedConnections {
+		if changes[connectionId] {
+			stillAdded = append(stillAdded, connectionId)
+		}
 	}
 
-	var connection struct {
-		EnabledClients []string `json:"enabled_clients"`
+	return sortedUnique(stillAdded)
+}
+
+// priorAddedConnections returns the connections an additive resource added
+// before this plan. A resource switching from authoritative mode takes over
+// every connection it lists, as it managed them until now.
+func (r *ApplicationConnectionsResource) priorAddedConnections(ctx context.Context, data ApplicationConnectionsResourceModel, priorData ApplicationConnectionsResourceModel, private privateStateGetter, diags *diag.Diagnostics) []string {
+	if data.Mode.ValueString() != connectionsModeAdditive {
+		return nil
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&connection); err != nil {
-		return nil, fmt.Errorf("failed to decode connection response: %w", err)
+	if priorData.Mode.ValueString() == connectionsModeAdditive {
+		return getAddedConnections(ctx, private, diags)
 	}
 
-	return connection.EnabledClients, nil
+	var listedConnections []string
+	diags.Append(data.EnabledConnectionIds.ElementsAs(ctx, &listedConnections, false)...)
+	return listedConnections
 }
 
-func (r *ApplicationConnectionsResource) applyConnectionState(ctx context.Context, accessToken string, allConnections []string, applicationId string, enabledConnectionIds []string) ([]string, error) {
+// connectionChanges maps the connections whose membership the resource sets
+// to whether the application should be enabled on them. Authoritative mode
+// sets every connection of the tenant. Additive mode only enables the listed
+// connections and disables the added ones that are no longer listed.
+func connectionChanges(mode string, allConnections []string, enabledConnectionIds []string, addedConnectionIds []string) map[string]bool {
+	changes := make(map[string]bool)
+
+	if mode == connectionsModeAdditive {
+		for _, connectionId := range addedConnectionIds {
+			changes[connectionId] = false
+		}
+	} else {
+		for _, connectionId := range allConnections {
+			changes[connectionId] = false
+		}
+	}
+
+	for _, connectionId := range enabledConnectionIds {
+		changes[connectionId] = true
+	}
+
+	return changes
+}
+
+// applyConnectionState enables or disables the application on the
+// connections of changes and returns the connections it changed.
+func (r *ApplicationConnectionsResource) applyConnectionState(ctx context.Context, allConnections []string, applicationId string, changes map[string]bool) ([]string, error) {
 	var managedConnections []string
 
-	// Create a set of enabled connections for quick lookup
-	enabledSet := make(map[string]bool)
-	for _, connId := range enabledConnectionIds {
-		enabledSet[connId] = true
+	// Get current enabled clients for all connections
+	allConnectionClients, err := r.getAllConnectionClients(ctx, allConnections)
+	if err != nil {
+		return nil, err
 	}
 
-	// Process each connection
-	for _, connectionId := range allConnections {
-		// Get current enabled clients for this connection
-		currentClients, err := r.getConnectionClients(ctx, accessToken, connectionId)
-		if err != nil {
-			continue // Skip if we can't access this connection
+	// Every connection to enable has to exist in the tenant. ModifyPlan
+	// checks this already, so only connections deleted since the plan fail
+	// here.
+	for _, connId := range sortedMapKeys(changes) {
+		if _, exists := allConnectionClients[connId]; changes[connId] && !exists {
+			return nil, withAttributePath(path.Root("enabled_connection_ids"), fmt.Errorf("connection %s does not exist in the tenant or is outside the scope of this resource", connId))
 		}
+	}
 
-		// Determine new client list
-		var newClients []string
-		
-		// Add all clients except our application
-		for _, clientId := range currentClients {
-			if clientId != applicationId {
-				newClients = append(newClients, clientId)
-			}
+	// Collect the connections where the application's membership changes
+	var pendingConnections []string
+	for _, connectionId := range allConnections {
+		currentClients, exists := allConnectionClients[connectionId]
+		if !exists {
+			continue // The connection was deleted since it was listed
 		}
 
-		// Add our application if it should be enabled for this connection
-		if enabledSet[connectionId] {
-			newClients = append(newClients, applicationId)
+		if enabled, managed := changes[connectionId]; managed && containsString(currentClients, applicationId) != enabled {
+			pendingConnections = append(pendingConnections, connectionId)
 		}
+	}
 
-		// Sort for consistent ordering
-		sort.Strings(newClients)
-		sort.Strings(currentClients)
+	// Queue the changes concurrently, so they are written together with the
+	// changes other resources make to the same connections.
+	changed := make([]bool, len(pendingConnections))
+	err = forEachConcurrently(ctx, len(pendingConnections), r.client.MaxConcurrency, func(ctx context.Context, i int) error {
+		connectionId := pendingConnections[i]
 
-		// Only update if the client list has changed
-		if !stringSlicesEqual(currentClients, newClients) {
-			err := r.updateConnectionClients(ctx, accessToken, connectionId, newClients)
-			if err != nil {
-				return nil, fmt.Errorf("failed to update connection %s: %w", connectionId, err)
+		connectionChanged, err := r.client.setConnectionClientEnabled(ctx, connectionId, applicationId, changes[connectionId])
+		if isNotFound(err) {
+			return nil // The connection was deleted in the meantime
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to update connection %s: %w", connectionId, err)
+			if apiErrorStatus(err) == http.StatusBadRequest && isClientChangeError(err) {
+				// The update carried only this application's change, so
+				// the rejected payload points at the application ID.
+				err = withAttributePath(path.Root("application_id"), err)
 			}
+			return err
+		}
+
+		changed[i] = connectionChanged
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, connectionId := range pendingConnections {
+		if changed[i] {
 			managedConnections = append(managedConnections, connectionId)
 		}
 	}
@@ -493,38 +842,137 @@ func (r *ApplicationConnectionsResource) applyConnectionState(ctx context.Contex
 	return managedConnections, nil
 }
 
-func (r *ApplicationConnectionsResource) updateConnectionClients(ctx context.Context, accessToken string, connectionId string, enabledClients []string) error {
-	url := fmt.Sprintf("https://%s/api/v2/connections/%s", r.client.Domain, connectionId)
+// addedConnectionsKey is the private state key under which additive
+// resources track the connections they added the application to.
+const addedConnectionsKey = "added_connection_ids"
+
+// privateStateGetter and privateStateSetter are the private state of the
+// framework's requests and responses.
+type privateStateGetter interface {
+	GetKey(ctx context.Context, key string) ([]byte, diag.Diagnostics)
+}
+
+type privateStateSetter interface {
+	SetKey(ctx context.Context, key string, value []byte) diag.Diagnostics
+}
 
-	payload := map[string]interface{}{
-		"enabled_clients": enabledClients,
+// getAddedConnections returns the connections tracked in private state.
+func getAddedConnections(ctx context.Context, private privateStateGetter, diags *diag.Diagnostics) []string {
+	value, getDiags := private.GetKey(ctx, addedConnectionsKey)
+	diags.Append(getDiags...)
+	if diags.HasError() || len(value) == 0 {
+		return nil
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+	var connectionIds []string
+	if err := json.Unmarshal(value, &connectionIds); err != nil {
+		diags.AddError(
+			"Invalid Private State",
+			fmt.Sprintf("The connections added by this resource could not be read from its private state: %s", err),
+		)
+		return nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PATCH", url, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return fmt.Errorf("failed to create update request: %w", err)
+	return connectionIds
+}
+
+// setAddedConnections tracks connectionIds in private state, removing the key
+// when there are none.
+func setAddedConnections(ctx context.Context, private privateStateSetter, connectionIds []string, diags *diag.Diagnostics) {
+	var value []byte
+	if len(connectionIds) > 0 {
+		var err error
+		if value, err = json.Marshal(connectionIds); err != nil {
+			diags.AddError("Invalid Private State", fmt.Sprintf("Failed to encode the connections added by this resource: %s", err))
+			return
+		}
+	}
+
+	diags.Append(private.SetKey(ctx, addedConnectionsKey, value)...)
+}
+
+// resolvePendingConnections settles the connection previews after an apply.
+// Previews computed at plan time are kept as planned; the ones that could not
+// be computed are empty, as nothing is pending anymore.
+func (m *ApplicationConnectionsResourceModel) resolvePendingConnections() {
+	if m.ConnectionsToEnable.IsUnknown() {
+		m.ConnectionsToEnable = types.MapValueMust(types.StringType, map[string]attr.Value{})
+	}
+	if m.ConnectionsToDisable.IsUnknown() {
+		m.ConnectionsToDisable = types.MapValueMust(types.StringType, map[string]attr.Value{})
 	}
+}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
+// knownStrings returns the elements of a string set, and whether the set and
+// all of its elements are known.
+func knownStrings(set types.Set) ([]string, bool) {
+	if set.IsUnknown() {
+		return nil, false
+	}
 
-	resp, err := r.client.HTTPClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make update request: %w", err)
+	var values []string
+	for _, element := range set.Elements() {
+		value, ok := element.(types.String)
+		if !ok || value.IsUnknown() {
+			return nil, false
+		}
+		if !value.IsNull() {
+			values = append(values, value.ValueString())
+		}
+	}
+
+	return values, true
+}
+
+// upgradeListToSet converts a string list of prior state into a set, dropping
+// duplicates a list could hold. Null and unknown lists stay null and unknown.
+func upgradeListToSet(ctx context.Context, list types.List, diags *diag.Diagnostics) types.Set {
+	if list.IsNull() {
+		return types.SetNull(types.StringType)
+	}
+	if list.IsUnknown() {
+		return types.SetUnknown(types.StringType)
+	}
+
+	var values []string
+	diags.Append(list.ElementsAs(ctx, &values, false)...)
+	if diags.HasError() {
+		return types.SetNull(types.StringType)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("update request failed with status %d: %s", resp.StatusCode, string(body))
+	set, setDiags := types.SetValueFrom(ctx, types.StringType, sortedUnique(values))
+	diags.Append(setDiags...)
+	return set
+}
+
+// intersectStrings returns the values that are also in others, in order.
+func intersectStrings(values []string, others []string) []string {
+	result := []string{}
+	for _, value := range values {
+		if containsString(others, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// nonNilStrings returns values, or an empty slice instead of nil so it
+// converts to an empty set rather than a null one.
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
 	}
+	return values
+}
 
-	return nil
+// sortedMapKeys returns the keys of m in sorted order.
+func sortedMapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // Helper function to compare string slices
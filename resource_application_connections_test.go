@@ -0,0 +1,744 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"bitbucket.org/cerifi/terraform-provider-auth0-connections/internal/auth0fake"
+)
+
+func TestAccApplicationConnectionsResource(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: testAccCheckConnectionClients(server, map[string][]string{
+			"con_db":     {"other_app"},
+			"con_google": {},
+			"con_github": {},
+		}),
+		Steps: []resource.TestStep{
+			// Create
+			{
+				Config: testAccApplicationConnectionsConfig(server, "app_1", "con_db", "con_google"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "id", "app_1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "application_id", "app_1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "managed_connection_ids.#", "2"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_enable.%", "2"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_enable.con_db", "Username-Password-Authentication"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_enable.con_google", "google-oauth2"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.%", "0"),
+					testAccCheckConnectionClients(server, map[string][]string{
+						"con_db":     {"app_1", "other_app"},
+						"con_google": {"app_1"},
+						"con_github": {},
+					}),
+				),
+			},
+			// Update
+			{
+				Config: testAccApplicationConnectionsConfig(server, "app_1", "con_google", "con_github"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "enabled_connection_ids.#", "2"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_enable.%", "1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_enable.con_github", "github"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.%", "1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.con_db", "Username-Password-Authentication"),
+					testAccCheckConnectionClients(server, map[string][]string{
+						"con_db":     {"other_app"},
+						"con_google": {"app_1"},
+						"con_github": {"app_1"},
+					}),
+				),
+			},
+			// Reordering the connections is not a change
+			{
+				Config:   testAccApplicationConnectionsConfig(server, "app_1", "con_github", "con_google", "con_github"),
+				PlanOnly: true,
+			},
+			// Import
+			{
+				ResourceName:      "auth0-connections_application_connections.test",
+				ImportState:       true,
+				ImportStateId:     "app_1",
+				ImportStateVerify: true,
+				// Read reports the live memberships, not what was changed by
+				// the last apply.
+				ImportStateVerifyIgnore: []string{"managed_connection_ids", "connections_to_enable", "connections_to_disable"},
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if len(states) != 1 {
+						return fmt.Errorf("got %d imported states, want 1", len(states))
+					}
+					if got := states[0].Attributes["managed_connection_ids.#"]; got != "2" {
+						return fmt.Errorf("got %s managed connections, want 2", got)
+					}
+					return nil
+				},
+			},
+			// Drift: the application is removed from one connection and added
+			// to another outside Terraform, which a refresh picks up.
+			{
+				PreConfig: func() {
+					server.SetConnectionClient("con_github", "app_1", false)
+					server.SetConnectionClient("con_db", "app_1", true)
+				},
+				RefreshState:       true,
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "enabled_connection_ids.#", "2"),
+					resource.TestCheckTypeSetElemAttr("auth0-connections_application_connections.test", "enabled_connection_ids.*", "con_db"),
+					resource.TestCheckTypeSetElemAttr("auth0-connections_application_connections.test", "enabled_connection_ids.*", "con_google"),
+				),
+			},
+			// Applying the configuration again reverts the drift.
+			{
+				Config: testAccApplicationConnectionsConfig(server, "app_1", "con_google", "con_github"),
+				Check: testAccCheckConnectionClients(server, map[string][]string{
+					"con_db":     {"other_app"},
+					"con_google": {"app_1"},
+					"con_github": {"app_1"},
+				}),
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_preservesOtherApplications(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: testAccCheckConnectionClients(server, map[string][]string{
+			"con_db":     {"other_app"},
+			"con_google": {},
+			"con_github": {},
+		}),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+resource "auth0-connections_application_connections" "app_1" {
+  application_id         = "app_1"
+  enabled_connection_ids = ["con_db", "con_google"]
+}
+
+resource "auth0-connections_application_connections" "app_2" {
+  application_id         = "app_2"
+  enabled_connection_ids = ["con_db", "con_github"]
+}
+`,
+				Check: testAccCheckConnectionClients(server, map[string][]string{
+					"con_db":     {"app_1", "app_2", "other_app"},
+					"con_google": {"app_1"},
+					"con_github": {"app_2"},
+				}),
+			},
+			{
+				Config: testAccProviderConfig(server) + `
+resource "auth0-connections_application_connections" "app_1" {
+  application_id         = "app_1"
+  enabled_connection_ids = ["con_google"]
+}
+
+resource "auth0-connections_application_connections" "app_2" {
+  application_id         = "app_2"
+  enabled_connection_ids = ["con_db", "con_github"]
+}
+`,
+				Check: testAccCheckConnectionClients(server, map[string][]string{
+					"con_db":     {"app_2", "other_app"},
+					"con_google": {"app_1"},
+					"con_github": {"app_2"},
+				}),
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_unknownConnection(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccApplicationConnectionsConfig(server, "app_1", "con_missing"),
+				ExpectError: regexp.MustCompile(`connection con_missing does not exist in the tenant`),
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_additive(t *testing.T) {
+	server := newTestAccServer(t)
+	// Someone else already enabled app_1 on con_github.
+	server.SetConnectionClient("con_github", "app_1", true)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		// Destroy removes the application from con_google only, as
+		// con_github and con_saml were enabled by someone else.
+		CheckDestroy: testAccCheckConnectionClients(server, map[string][]string{
+			"con_db":     {"other_app"},
+			"con_google": {},
+			"con_github": {"app_1"},
+			"con_saml":   {"app_1"},
+		}),
+		Steps: []resource.TestStep{
+			// Create only adds the application
+			{
+				Config: testAccAdditiveApplicationConnectionsConfig(server, "app_1", "con_db", "con_google"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "mode", "additive"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.%", "0"),
+					testAccCheckConnectionClients(server, map[string][]string{
+						"con_db":     {"app_1", "other_app"},
+						"con_google": {"app_1"},
+						"con_github": {"app_1"},
+					}),
+				),
+			},
+			// Connections enabled outside Terraform are not a diff
+			{
+				PreConfig: func() {
+					server.AddConnection(auth0fake.Connection{ID: "con_saml", Name: "saml", Strategy: "samlp", EnabledClients: []string{"app_1"}})
+				},
+				Config:   testAccAdditiveApplicationConnectionsConfig(server, "app_1", "con_db", "con_google"),
+				PlanOnly: true,
+			},
+			// Unlisting removes only the connections the resource added
+			{
+				Config: testAccAdditiveApplicationConnectionsConfig(server, "app_1", "con_google", "con_github"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.%", "1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.con_db", "Username-Password-Authentication"),
+					testAccCheckConnectionClients(server, map[string][]string{
+						"con_db":     {"other_app"},
+						"con_google": {"app_1"},
+						"con_github": {"app_1"},
+						"con_saml":   {"app_1"},
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_additiveReplace(t *testing.T) {
+	server := newTestAccServer(t)
+	// Someone else already enabled app_2 on con_google.
+	server.SetConnectionClient("con_google", "app_2", true)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: testAccCheckConnectionClients(server, map[string][]string{
+			"con_db":     {"other_app"},
+			"con_google": {"app_2"},
+		}),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAdditiveApplicationConnectionsConfig(server, "app_1", "con_google"),
+			},
+			// The new instance doesn't inherit the connections added for
+			// app_1, so con_google is not previewed as disabled for app_2.
+			{
+				Config: testAccAdditiveApplicationConnectionsConfig(server, "app_2", "con_db"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_enable.%", "1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.test", "connections_to_disable.%", "0"),
+					testAccCheckConnectionClients(server, map[string][]string{
+						"con_db":     {"app_2", "other_app"},
+						"con_google": {"app_2"},
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_invalidMode(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+resource "auth0-connections_application_connections" "test" {
+  application_id         = "app_1"
+  mode                   = "merge"
+  enabled_connection_ids = ["con_db"]
+}
+`,
+				ExpectError: regexp.MustCompile(`The mode must be "authoritative" or "additive"`),
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_scoped(t *testing.T) {
+	server := newTestAccServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_corp", Name: "corp-okta", Strategy: "samlp"})
+	server.AddConnection(auth0fake.Connection{ID: "con_partner", Name: "partner-adfs", Strategy: "samlp", EnabledClients: []string{"app_1"}})
+
+	config := testAccProviderConfig(server) + `
+resource "auth0-connections_application_connections" "social" {
+  application_id         = "app_1"
+  enabled_connection_ids = ["con_google"]
+
+  scope {
+    strategies = ["google-oauth2", "github"]
+  }
+}
+
+resource "auth0-connections_application_connections" "enterprise" {
+  application_id         = "app_1"
+  enabled_connection_ids = ["con_corp"]
+
+  scope {
+    strategies    = ["samlp"]
+    name_patterns = ["corp-*"]
+  }
+}
+`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		// Out of both scopes, con_db and con_partner are left alone.
+		CheckDestroy: testAccCheckConnectionClients(server, map[string][]string{
+			"con_db":      {"other_app"},
+			"con_google":  {},
+			"con_github":  {},
+			"con_corp":    {},
+			"con_partner": {"app_1"},
+		}),
+		Steps: []resource.TestStep{
+			{
+				PreConfig: func() {
+					server.SetConnectionClient("con_github", "app_1", true)
+					server.SetConnectionClient("con_db", "app_1", true)
+				},
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.social", "id", "app_1?strategies=github&strategies=google-oauth2"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.enterprise", "id", "app_1?name_patterns=corp-%2A&strategies=samlp"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.social", "connections_to_disable.%", "1"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.social", "connections_to_disable.con_github", "github"),
+					resource.TestCheckResourceAttr("auth0-connections_application_connections.enterprise", "connections_to_disable.%", "0"),
+					testAccCheckConnectionClients(server, map[string][]string{
+						"con_db":      {"app_1", "other_app"},
+						"con_google":  {"app_1"},
+						"con_github":  {},
+						"con_corp":    {"app_1"},
+						"con_partner": {"app_1"},
+					}),
+				),
+			},
+			{
+				ResourceName:      "auth0-connections_application_connections.enterprise",
+				ImportState:       true,
+				ImportStateId:     "app_1?strategies=samlp&name_patterns=corp-*",
+				ImportStateVerify: true,
+				// Read reports the live memberships, not what was changed by
+				// the last apply.
+				ImportStateVerifyIgnore: []string{"managed_connection_ids", "connections_to_enable", "connections_to_disable"},
+			},
+			// The scopes keep the resources from fighting over app_1.
+			{
+				PreConfig: func() {
+					server.SetConnectionClient("con_db", "app_1", false)
+				},
+				Config:   config,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccApplicationConnectionsResource_outsideScope(t *testing.T) {
+	server := newTestAccServer(t)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccProviderConfig(server) + `
+resource "auth0-connections_application_connections" "test" {
+  application_id         = "app_1"
+  enabled_connection_ids = ["con_db"]
+
+  scope {
+    connection_ids = ["con_google"]
+  }
+}
+`,
+				ExpectError: regexp.MustCompile(`Connection Outside Scope`),
+			},
+		},
+	})
+}
+
+func testAccAdditiveApplicationConnectionsConfig(server *auth0fake.Server, applicationId string, connectionIds ...string) string {
+	return strings.Replace(
+		testAccApplicationConnectionsConfig(server, applicationId, connectionIds...),
+		"  enabled_connection_ids",
+		"  mode                   = \"additive\"\n  enabled_connection_ids",
+		1,
+	)
+}
+
+func testAccApplicationConnectionsConfig(server *auth0fake.Server, applicationId string, connectionIds ...string) string {
+	quoted := make([]string, len(connectionIds))
+	for i, connectionId := range connectionIds {
+		quoted[i] = fmt.Sprintf("%q", connectionId)
+	}
+
+	return testAccProviderConfig(server) + fmt.Sprintf(`
+resource "auth0-connections_application_connections" "test" {
+  application_id         = %q
+  enabled_connection_ids = [%s]
+}
+`, applicationId, strings.Join(quoted, ", "))
+}
+
+// testAccCheckConnectionClients checks the clients enabled on each connection
+// of the fake tenant.
+func testAccCheckConnectionClients(server *auth0fake.Server, want map[string][]string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		for connectionId, wantClients := range want {
+			got := server.ConnectionClients(connectionId)
+			if !stringSlicesEqual(got, sortedCopy(wantClients)) {
+				return fmt.Errorf("connection %s has clients %v, want %v", connectionId, got, wantClients)
+			}
+		}
+		return nil
+	}
+}
+
+func TestApplicationConnectionsResourceUpgradeStateV0(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t)
+	server.AddConnection(auth0fake.Connection{ID: "con_a", Name: "a", Strategy: "auth0", EnabledClients: []string{"app_1"}})
+	server.AddConnection(auth0fake.Connection{ID: "con_b", Name: "b", Strategy: "auth0", EnabledClients: []string{"app_1"}})
+	r := &ApplicationConnectionsResource{client: newTestClient(t, server)}
+
+	var schemaResp fwresource.SchemaResponse
+	r.Schema(ctx, fwresource.SchemaRequest{}, &schemaResp)
+
+	upgrader := r.UpgradeState(ctx)[0]
+	priorType := upgrader.PriorSchema.Type().TerraformType(ctx)
+	stringList := func(values ...string) tftypes.Value {
+		elements := make([]tftypes.Value, len(values))
+		for i, value := range values {
+			elements[i] = tftypes.NewValue(tftypes.String, value)
+		}
+		return tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, elements)
+	}
+
+	req := fwresource.UpgradeStateRequest{
+		State: &tfsdk.State{
+			Schema: *upgrader.PriorSchema,
+			Raw: tftypes.NewValue(priorType, map[string]tftypes.Value{
+				"id":                     tftypes.NewValue(tftypes.String, "app_1"),
+				"application_id":         tftypes.NewValue(tftypes.String, "app_1"),
+				"enabled_connection_ids": stringList("con_b", "con_a", "con_b"),
+				"managed_connection_ids": stringList("con_a"),
+			}),
+		},
+	}
+	resp := fwresource.UpgradeStateResponse{
+		State: tfsdk.State{
+			Schema: schemaResp.Schema,
+			Raw:    tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil),
+		},
+	}
+
+	upgrader.StateUpgrader(ctx, req, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("StateUpgrader: %v", resp.Diagnostics)
+	}
+
+	var data ApplicationConnectionsResourceModel
+	if diags := resp.State.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("State.Get: %v", diags)
+	}
+
+	var enabled, managed []string
+	data.EnabledConnectionIds.ElementsAs(ctx, &enabled, false)
+	data.ManagedConnectionIds.ElementsAs(ctx, &managed, false)
+
+	if data.ApplicationId.ValueString() != "app_1" || data.Id.ValueString() != "app_1" {
+		t.Errorf("got id %s and application_id %s, want app_1", data.Id, data.ApplicationId)
+	}
+	if got := sortedCopy(enabled); !stringSlicesEqual(got, []string{"con_a", "con_b"}) {
+		t.Errorf("got enabled_connection_ids %v, want [con_a con_b]", got)
+	}
+	if !stringSlicesEqual(managed, []string{"con_a"}) {
+		t.Errorf("got managed_connection_ids %v, want [con_a]", managed)
+	}
+	if data.Mode.ValueString() != connectionsModeAuthoritative {
+		t.Errorf("got mode %s, want %s", data.Mode, connectionsModeAuthoritative)
+	}
+
+	// A plan without a refresh right after the upgrade has nothing to do.
+	plan := tfsdk.Plan{Schema: resp.State.Schema, Raw: resp.State.Raw}
+	planReq := fwresource.ModifyPlanRequest{Plan: plan, State: resp.State}
+	planResp := fwresource.ModifyPlanResponse{Plan: plan}
+
+	r.ModifyPlan(ctx, planReq, &planResp)
+	if planResp.Diagnostics.HasError() {
+		t.Fatalf("ModifyPlan: %v", planResp.Diagnostics)
+	}
+	if !planResp.Plan.Raw.Equal(resp.State.Raw) {
+		t.Errorf("got plan %s after the upgrade, want the upgraded state %s", planResp.Plan.Raw, resp.State.Raw)
+	}
+}
+
+func TestApplicationConnectionsResourceModifyPlan(t *testing.T) {
+	ctx := context.Background()
+	server := newTestAccServer(t)
+	server.SetConnectionClient("con_db", "app_1", true)
+	server.SetConnectionClient("con_github", "app_1", true)
+	r := &ApplicationConnectionsResource{client: newTestClient(t, server)}
+
+	var schemaResp fwresource.SchemaResponse
+	r.Schema(ctx, fwresource.SchemaRequest{}, &schemaResp)
+	objectType := schemaResp.Schema.Type().TerraformType(ctx)
+	stringSet := tftypes.Set{ElementType: tftypes.String}
+	stringMap := tftypes.Map{ElementType: tftypes.String}
+
+	plan := tfsdk.Plan{
+		Schema: schemaResp.Schema,
+		Raw: tftypes.NewValue(objectType, map[string]tftypes.Value{
+			"id":             tftypes.NewValue(tftypes.String, "app_1"),
+			"application_id": tftypes.NewValue(tftypes.String, "app_1"),
+			"mode":           tftypes.NewValue(tftypes.String, connectionsModeAuthoritative),
+			"enabled_connection_ids": tftypes.NewValue(stringSet, []tftypes.Value{
+				tftypes.NewValue(tftypes.String, "con_db"),
+				tftypes.NewValue(tftypes.String, "con_google"),
+			}),
+			"managed_connection_ids": tftypes.NewValue(stringSet, tftypes.UnknownValue),
+			"connections_to_enable":  tftypes.NewValue(stringMap, tftypes.UnknownValue),
+			"connections_to_disable": tftypes.NewValue(stringMap, tftypes.UnknownValue),
+			"scope":                  tftypes.NewValue(objectType.(tftypes.Object).AttributeTypes["scope"], nil),
+		}),
+	}
+	req := fwresource.ModifyPlanRequest{
+		Plan:  plan,
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(objectType, nil)},
+	}
+	resp := fwresource.ModifyPlanResponse{Plan: plan}
+
+	r.ModifyPlan(ctx, req, &resp)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("ModifyPlan: %v", resp.Diagnostics)
+	}
+
+	var data ApplicationConnectionsResourceModel
+	if diags := resp.Plan.Get(ctx, &data); diags.HasError() {
+		t.Fatalf("Plan.Get: %v", diags)
+	}
+
+	var toEnable, toDisable map[string]string
+	data.ConnectionsToEnable.ElementsAs(ctx, &toEnable, false)
+	data.ConnectionsToDisable.ElementsAs(ctx, &toDisable, false)
+
+	if len(toEnable) != 1 || toEnable["con_google"] != "google-oauth2" {
+		t.Errorf("got connections_to_enable %v, want con_google", toEnable)
+	}
+	if len(toDisable) != 1 || toDisable["con_github"] != "github" {
+		t.Errorf("got connections_to_disable %v, want con_github", toDisable)
+	}
+
+	warnings := resp.Diagnostics.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Detail(), "github (con_github)") {
+		t.Errorf("got warnings %v, want one naming github (con_github)", warnings)
+	}
+}
+
+func TestApplyConnectionStateRejectsDeletedConnection(t *testing.T) {
+	server := newTestAccServer(t)
+	r := &ApplicationConnectionsResource{client: newTestClient(t, server)}
+
+	// con_saml was planned but deleted before the apply.
+	changes := map[string]bool{"con_google": true, "con_saml": true}
+	_, err := r.applyConnectionState(context.Background(), []string{"con_db", "con_google", "con_saml"}, "app_1", changes)
+
+	var attrErr *attributeError
+	if !errors.As(err, &attrErr) || !attrErr.path.Equal(path.Root("enabled_connection_ids")) {
+		t.Fatalf("got error %v, want one on enabled_connection_ids", err)
+	}
+	if !strings.Contains(err.Error(), "con_saml") {
+		t.Errorf("got error %q, want it to name con_saml", err)
+	}
+	if got := server.ConnectionClients("con_google"); len(got) != 0 {
+		t.Errorf("got clients %v on con_google, want nothing changed", got)
+	}
+}
+
+// testProviderServer returns the provider served over protocol 6, configured
+// for the fake tenant, to drive plans and applies like Terraform does.
+func testProviderServer(t *testing.T, server *auth0fake.Server) tfprotov6.ProviderServer {
+	t.Helper()
+
+	ctx := context.Background()
+	providerServer, err := providerserver.NewProtocol6WithError(New("test")())()
+	if err != nil {
+		t.Fatalf("NewProtocol6WithError: %s", err)
+	}
+
+	var schemaResp provider.SchemaResponse
+	New("test")().Schema(ctx, provider.SchemaRequest{}, &schemaResp)
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+
+	attributes := map[string]string{
+		"domain":             "tenant.example.com",
+		"client_id":          server.ClientID,
+		"client_secret":      server.ClientSecret,
+		"api_base_url":       server.APIBaseURL(),
+		"token_url":          server.TokenURL(),
+		"write_batch_window": "10ms",
+	}
+	values := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+	for name, attributeType := range objectType.AttributeTypes {
+		if value, ok := attributes[name]; ok {
+			values[name] = tftypes.NewValue(attributeType, value)
+		} else {
+			values[name] = tftypes.NewValue(attributeType, nil)
+		}
+	}
+
+	resp, err := providerServer.ConfigureProvider(ctx, &tfprotov6.ConfigureProviderRequest{
+		Config: testDynamicValue(t, objectType, tftypes.NewValue(objectType, values)),
+	})
+	if err != nil {
+		t.Fatalf("ConfigureProvider: %s", err)
+	}
+	checkProtocolDiagnostics(t, "ConfigureProvider", resp.Diagnostics)
+
+	return providerServer
+}
+
+func testDynamicValue(t *testing.T, valueType tftypes.Type, value tftypes.Value) *tfprotov6.DynamicValue {
+	t.Helper()
+
+	dynamicValue, err := tfprotov6.NewDynamicValue(valueType, value)
+	if err != nil {
+		t.Fatalf("NewDynamicValue: %s", err)
+	}
+	return &dynamicValue
+}
+
+// checkProtocolDiagnostics fails the test on error diagnostics.
+func checkProtocolDiagnostics(t *testing.T, call string, diags []*tfprotov6.Diagnostic) {
+	t.Helper()
+
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			t.Fatalf("%s: %s: %s", call, d.Summary, d.Detail)
+		}
+	}
+}
+
+func TestApplicationConnectionsResourceApplyMatchesPlanWithoutRefresh(t *testing.T) {
+	ctx := context.Background()
+	server := newTestAccServer(t)
+	// Since the last refresh, app_1 was enabled on con_github outside
+	// Terraform, and con_google, which the state lists, was never enabled.
+	server.SetConnectionClient("con_github", "app_1", true)
+	providerServer := testProviderServer(t, server)
+
+	r := &ApplicationConnectionsResource{}
+	var schemaResp fwresource.SchemaResponse
+	r.Schema(ctx, fwresource.SchemaRequest{}, &schemaResp)
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	stringSet := tftypes.Set{ElementType: tftypes.String}
+	stringMap := tftypes.Map{ElementType: tftypes.String}
+	typeName := "auth0-connections_application_connections"
+
+	prior := tftypes.NewValue(objectType, map[string]tftypes.Value{
+		"id":                     tftypes.NewValue(tftypes.String, "app_1"),
+		"application_id":         tftypes.NewValue(tftypes.String, "app_1"),
+		"mode":                   tftypes.NewValue(tftypes.String, connectionsModeAuthoritative),
+		"enabled_connection_ids": tftypes.NewValue(stringSet, []tftypes.Value{tftypes.NewValue(tftypes.String, "con_google")}),
+		"managed_connection_ids": tftypes.NewValue(stringSet, []tftypes.Value{tftypes.NewValue(tftypes.String, "con_google")}),
+		"connections_to_enable":  tftypes.NewValue(stringMap, map[string]tftypes.Value{}),
+		"connections_to_disable": tftypes.NewValue(stringMap, map[string]tftypes.Value{}),
+		"scope":                  tftypes.NewValue(objectType.AttributeTypes["scope"], nil),
+	})
+	config := tftypes.NewValue(objectType, map[string]tftypes.Value{
+		"id":                     tftypes.NewValue(tftypes.String, nil),
+		"application_id":         tftypes.NewValue(tftypes.String, "app_1"),
+		"mode":                   tftypes.NewValue(tftypes.String, nil),
+		"enabled_connection_ids": tftypes.NewValue(stringSet, []tftypes.Value{tftypes.NewValue(tftypes.String, "con_google")}),
+		"managed_connection_ids": tftypes.NewValue(stringSet, nil),
+		"connections_to_enable":  tftypes.NewValue(stringMap, nil),
+		"connections_to_disable": tftypes.NewValue(stringMap, nil),
+		"scope":                  tftypes.NewValue(objectType.AttributeTypes["scope"], nil),
+	})
+
+	planResp, err := providerServer.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         typeName,
+		PriorState:       testDynamicValue(t, objectType, prior),
+		ProposedNewState: testDynamicValue(t, objectType, prior),
+		Config:           testDynamicValue(t, objectType, config),
+	})
+	if err != nil {
+		t.Fatalf("PlanResourceChange: %s", err)
+	}
+	checkProtocolDiagnostics(t, "PlanResourceChange", planResp.Diagnostics)
+
+	applyResp, err := providerServer.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:       typeName,
+		PriorState:     testDynamicValue(t, objectType, prior),
+		PlannedState:   planResp.PlannedState,
+		Config:         testDynamicValue(t, objectType, config),
+		PlannedPrivate: planResp.PlannedPrivate,
+	})
+	if err != nil {
+		t.Fatalf("ApplyResourceChange: %s", err)
+	}
+	checkProtocolDiagnostics(t, "ApplyResourceChange", applyResp.Diagnostics)
+
+	planned, err := planResp.PlannedState.Unmarshal(objectType)
+	if err != nil {
+		t.Fatalf("planned state: %s", err)
+	}
+	applied, err := applyResp.NewState.Unmarshal(objectType)
+	if err != nil {
+		t.Fatalf("applied state: %s", err)
+	}
+
+	// Terraform rejects an apply whose result differs from a known planned
+	// value.
+	var plannedAttributes, appliedAttributes map[string]tftypes.Value
+	if err := planned.As(&plannedAttributes); err != nil {
+		t.Fatalf("planned state: %s", err)
+	}
+	if err := applied.As(&appliedAttributes); err != nil {
+		t.Fatalf("applied state: %s", err)
+	}
+	for name, value := range plannedAttributes {
+		if value.IsFullyKnown() && !value.Equal(appliedAttributes[name]) {
+			t.Errorf("%s: planned %s, applied %s", name, value, appliedAttributes[name])
+		}
+	}
+
+	if got := server.ConnectionClients("con_google"); !stringSlicesEqual(got, []string{"app_1"}) {
+		t.Errorf("got clients %v on con_google, want app_1", got)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+const (
+	// defaultRetryMaxAttempts is the number of attempts made for a single
+	// Management API call when retry_max_attempts is not configured.
+	defaultRetryMaxAttempts = 5
+
+	// defaultRetryMaxWait is the total time a single Management API call may
+	// spend waiting between attempts when retry_max_wait is not configured.
+	defaultRetryMaxWait = 2 * time.Minute
+
+	retryBaseDelay = 250 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+// retryTransport retries requests that fail with a rate limit (429), a
+// server error (5xx) or a transport error. It honours Auth0's Retry-After and
+// X-RateLimit-Reset headers, falls back to jittered exponential backoff, and
+// gives up once either the attempt or the total wait budget is spent.
+type retryTransport struct {
+	base        http.RoundTripper
+	maxAttempts int
+	maxWait     time.Duration
+}
+
+func newRetryTransport(base http.RoundTripper, maxAttempts int, maxWait time.Duration) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return &retryTransport{
+		base:        base,
+		maxAttempts: maxAttempts,
+		maxWait:     maxWait,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attemptReq := req
+	var waited time.Duration
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		// Nothing to retry once the caller has given up.
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		if attempt >= t.maxAttempts {
+			return resp, err
+		}
+
+		// A request body that can't be replayed can't be retried either.
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+
+		delay := retryDelay(resp, attempt, time.Now())
+		if waited+delay > t.maxWait {
+			return resp, err
+		}
+
+		fields := map[string]interface{}{
+			"method":  req.Method,
+			"url":     req.URL.Redacted(),
+			"attempt": attempt,
+			"delay":   delay.String(),
+		}
+		if err != nil {
+			fields["reason"] = err.Error()
+		} else {
+			fields["reason"] = resp.Status
+			fields["rate_limit_limit"] = resp.Header.Get("X-RateLimit-Limit")
+			fields["rate_limit_remaining"] = resp.Header.Get("X-RateLimit-Remaining")
+			fields["rate_limit_reset"] = resp.Header.Get("X-RateLimit-Reset")
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		tflog.Debug(ctx, "Retrying Auth0 Management API request", fields)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		waited += delay
+
+		attemptReq = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body for retry: %w", err)
+			}
+			attemptReq.Body = body
+		}
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// retryDelay returns how long to wait before the next attempt. Server hints
+// win over the computed backoff: Retry-After first, then the rate limit
+// window reset reported by X-RateLimit-Reset once the limit is exhausted.
+func retryDelay(resp *http.Response, attempt int, now time.Time) time.Duration {
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+			if date, err := http.ParseTime(retryAfter); err == nil {
+				if delay := date.Sub(now); delay > 0 {
+					return delay
+				}
+				return 0
+			}
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				// Add a little jitter so parallel callers don't all wake
+				// up at the very start of the next window.
+				delay := time.Unix(reset, 0).Sub(now) + time.Duration(rand.Int63n(int64(retryBaseDelay)))
+				if delay > 0 {
+					return delay
+				}
+			}
+		}
+	}
+
+	backoff := retryBaseDelay << (attempt - 1)
+	if backoff > retryMaxDelay || backoff <= 0 {
+		backoff = retryMaxDelay
+	}
+
+	// Equal jitter: wait between half and the full backoff.
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
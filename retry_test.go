@@ -0,0 +1,191 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		status   int
+		header   map[string]string
+		attempt  int
+		min, max time.Duration
+	}{
+		{
+			name:    "retry-after seconds",
+			status:  http.StatusTooManyRequests,
+			header:  map[string]string{"Retry-After": "7"},
+			attempt: 1,
+			min:     7 * time.Second,
+			max:     7 * time.Second,
+		},
+		{
+			name:    "retry-after date",
+			status:  http.StatusServiceUnavailable,
+			header:  map[string]string{"Retry-After": now.Add(30 * time.Second).Format(http.TimeFormat)},
+			attempt: 1,
+			min:     30 * time.Second,
+			max:     30 * time.Second,
+		},
+		{
+			name:    "retry-after date in the past",
+			status:  http.StatusServiceUnavailable,
+			header:  map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)},
+			attempt: 3,
+			min:     0,
+			max:     0,
+		},
+		{
+			name:   "retry-after wins over rate limit reset",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{
+				"Retry-After":           "2",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(time.Minute).Unix(), 10),
+			},
+			attempt: 1,
+			min:     2 * time.Second,
+			max:     2 * time.Second,
+		},
+		{
+			name:   "rate limit reset",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(20*time.Second).Unix(), 10),
+			},
+			attempt: 1,
+			min:     20 * time.Second,
+			max:     20*time.Second + retryBaseDelay,
+		},
+		{
+			name:   "rate limit not exhausted",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "3",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(20*time.Second).Unix(), 10),
+			},
+			attempt: 1,
+			min:     retryBaseDelay / 2,
+			max:     retryBaseDelay,
+		},
+		{
+			name:   "rate limit reset in the past",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(now.Add(-time.Minute).Unix(), 10),
+			},
+			attempt: 2,
+			min:     retryBaseDelay,
+			max:     2 * retryBaseDelay,
+		},
+		{
+			name:    "invalid retry-after",
+			status:  http.StatusServiceUnavailable,
+			header:  map[string]string{"Retry-After": "soon"},
+			attempt: 1,
+			min:     retryBaseDelay / 2,
+			max:     retryBaseDelay,
+		},
+		{
+			name:    "exponential backoff",
+			status:  http.StatusBadGateway,
+			attempt: 3,
+			min:     2 * retryBaseDelay,
+			max:     4 * retryBaseDelay,
+		},
+		{
+			name:    "backoff is capped",
+			status:  http.StatusBadGateway,
+			attempt: 40,
+			min:     retryMaxDelay / 2,
+			max:     retryMaxDelay,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for key, value := range tt.header {
+				resp.Header.Set(key, value)
+			}
+
+			for i := 0; i < 20; i++ {
+				if got := retryDelay(resp, tt.attempt, now); got < tt.min || got > tt.max {
+					t.Fatalf("got %s, want between %s and %s", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+// roundTripperFunc answers requests with a function.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryStopsAtMaxWait(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       map[string]string
+		maxWait      time.Duration
+		wantAttempts int
+	}{
+		// Backoff waits at least 125ms and then at least 250ms, so the
+		// second wait would exceed the budget.
+		{"backoff", nil, 300 * time.Millisecond, 2},
+		// A server hint beyond the budget isn't waited for at all.
+		{"retry-after beyond budget", map[string]string{"Retry-After": "60"}, time.Second, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			transport := newRetryTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				resp := &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader("")),
+				}
+				for key, value := range tt.header {
+					resp.Header.Set(key, value)
+				}
+				return resp, nil
+			}), 10, tt.maxWait)
+
+			req, err := http.NewRequest(http.MethodGet, "https://tenant.example.com/api/v2/connections", nil)
+			if err != nil {
+				t.Fatalf("NewRequest: %s", err)
+			}
+
+			start := time.Now()
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %s", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("got status %d, want the last 503", resp.StatusCode)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.wantAttempts)
+			}
+			if elapsed := time.Since(start); elapsed > tt.maxWait {
+				t.Errorf("waited %s, more than retry_max_wait %s", elapsed, tt.maxWait)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ApplicationConnectionsScopeModel describes the scope block of the
+// application connections resource.
+type ApplicationConnectionsScopeModel struct {
+	Strategies    types.Set `tfsdk:"strategies"`
+	ConnectionIds types.Set `tfsdk:"connection_ids"`
+	NamePatterns  types.Set `tfsdk:"name_patterns"`
+}
+
+// connectionScope limits an application connections resource to a subset of
+// the tenant's connections. A connection is in scope when it matches every
+// criterion that is set; the zero value covers the whole tenant.
+type connectionScope struct {
+	Strategies    []string
+	ConnectionIds []string
+	NamePatterns  []string
+}
+
+// The keys of a scope in a composite resource ID.
+const (
+	scopeStrategiesKey    = "strategies"
+	scopeConnectionIdsKey = "connection_ids"
+	scopeNamePatternsKey  = "name_patterns"
+)
+
+// scopeFromModel converts the scope block into a connectionScope, and reports
+// whether all of it is known. A missing block is the whole tenant.
+func scopeFromModel(model *ApplicationConnectionsScopeModel) (connectionScope, bool) {
+	var scope connectionScope
+	if model == nil {
+		return scope, true
+	}
+
+	var known [3]bool
+	scope.Strategies, known[0] = knownStrings(model.Strategies)
+	scope.ConnectionIds, known[1] = knownStrings(model.ConnectionIds)
+	scope.NamePatterns, known[2] = knownStrings(model.NamePatterns)
+
+	scope.Strategies = sortedUnique(scope.Strategies)
+	scope.ConnectionIds = sortedUnique(scope.ConnectionIds)
+	scope.NamePatterns = sortedUnique(scope.NamePatterns)
+
+	return scope, known[0] && known[1] && known[2]
+}
+
+// model converts the scope into a scope block, or nil for the whole tenant.
+func (s connectionScope) model(ctx context.Context, diags *diag.Diagnostics) *ApplicationConnectionsScopeModel {
+	if s.isTenant() {
+		return nil
+	}
+
+	setOrNull := func(values []string) types.Set {
+		if len(values) == 0 {
+			return types.SetNull(types.StringType)
+		}
+		set, setDiags := types.SetValueFrom(ctx, types.StringType, values)
+		diags.Append(setDiags...)
+		return set
+	}
+
+	return &ApplicationConnectionsScopeModel{
+		Strategies:    setOrNull(s.Strategies),
+		ConnectionIds: setOrNull(s.ConnectionIds),
+		NamePatterns:  setOrNull(s.NamePatterns),
+	}
+}
+
+// isTenant reports whether the scope covers the whole tenant.
+func (s connectionScope) isTenant() bool {
+	return len(s.Strategies) == 0 && len(s.ConnectionIds) == 0 && len(s.NamePatterns) == 0
+}
+
+// contains reports whether conn is in scope.
+func (s connectionScope) contains(conn Auth0Connection) bool {
+	if len(s.Strategies) > 0 && !containsString(s.Strategies, conn.Strategy) {
+		return false
+	}
+
+	if len(s.ConnectionIds) > 0 && !containsString(s.ConnectionIds, conn.Id) {
+		return false
+	}
+
+	if len(s.NamePatterns) > 0 {
+		matched := false
+		for _, pattern := range s.NamePatterns {
+			// Patterns are validated up front, so errors can't happen here.
+			if ok, _ := path.Match(pattern, conn.Name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resourceId returns the composite ID of the resource managing applicationId
+// within the scope: the application ID, followed by the scope as a query
+// string unless the scope is the whole tenant, e.g.
+// "abc123?strategies=github&strategies=google-oauth2".
+func (s connectionScope) resourceId(applicationId string) string {
+	if s.isTenant() {
+		return applicationId
+	}
+
+	query := url.Values{}
+	for _, strategy := range s.Strategies {
+		query.Add(scopeStrategiesKey, strategy)
+	}
+	for _, connectionId := range s.ConnectionIds {
+		query.Add(scopeConnectionIdsKey, connectionId)
+	}
+	for _, pattern := range s.NamePatterns {
+		query.Add(scopeNamePatternsKey, pattern)
+	}
+
+	return applicationId + "?" + query.Encode()
+}
+
+// parseResourceId splits a composite resource ID into the application ID and
+// the scope.
+func parseResourceId(id string) (string, connectionScope, error) {
+	var scope connectionScope
+
+	applicationId, rawQuery, scoped := strings.Cut(id, "?")
+	if applicationId == "" {
+		return "", scope, fmt.Errorf("missing application ID in %q", id)
+	}
+	if !scoped {
+		return applicationId, scope, nil
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", scope, fmt.Errorf("invalid scope in %q: %w", id, err)
+	}
+
+	for key, values := range query {
+		switch key {
+		case scopeStrategiesKey:
+			scope.Strategies = sortedUnique(values)
+		case scopeConnectionIdsKey:
+			scope.ConnectionIds = sortedUnique(values)
+		case scopeNamePatternsKey:
+			for _, pattern := range values {
+				if err := validateNamePattern(pattern); err != nil {
+					return "", scope, err
+				}
+			}
+			scope.NamePatterns = sortedUnique(values)
+		default:
+			return "", scope, fmt.Errorf("unknown scope key %q in %q, expected %s, %s or %s", key, id, scopeStrategiesKey, scopeConnectionIdsKey, scopeNamePatternsKey)
+		}
+	}
+
+	return applicationId, scope, nil
+}
+
+// validateNamePattern checks that pattern is a valid path.Match pattern.
+func validateNamePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+	}
+	return nil
+}
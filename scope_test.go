@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestConnectionScopeContains(t *testing.T) {
+	google := Auth0Connection{Id: "con_google", Name: "google-oauth2", Strategy: "google-oauth2"}
+	corp := Auth0Connection{Id: "con_corp", Name: "corp-okta", Strategy: "samlp"}
+	partner := Auth0Connection{Id: "con_partner", Name: "partner-adfs", Strategy: "samlp"}
+
+	tests := []struct {
+		name  string
+		scope connectionScope
+		want  map[string]bool
+	}{
+		{"whole tenant", connectionScope{}, map[string]bool{"con_google": true, "con_corp": true, "con_partner": true}},
+		{"strategies", connectionScope{Strategies: []string{"samlp"}}, map[string]bool{"con_corp": true, "con_partner": true}},
+		{"connection ids", connectionScope{ConnectionIds: []string{"con_google"}}, map[string]bool{"con_google": true}},
+		{"name patterns", connectionScope{NamePatterns: []string{"corp-*", "google-*"}}, map[string]bool{"con_google": true, "con_corp": true}},
+		{"every criterion", connectionScope{Strategies: []string{"samlp"}, NamePatterns: []string{"*-okta", "google-*"}}, map[string]bool{"con_corp": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, conn := range []Auth0Connection{google, corp, partner} {
+				if got := tt.scope.contains(conn); got != tt.want[conn.Id] {
+					t.Errorf("contains(%s) = %t, want %t", conn.Id, got, tt.want[conn.Id])
+				}
+			}
+		})
+	}
+}
+
+func TestResourceIdRoundTrip(t *testing.T) {
+	tests := []struct {
+		scope connectionScope
+		id    string
+	}{
+		{connectionScope{}, "app_1"},
+		{connectionScope{Strategies: []string{"github", "google-oauth2"}}, "app_1?strategies=github&strategies=google-oauth2"},
+		{connectionScope{ConnectionIds: []string{"con_1"}, NamePatterns: []string{"corp-*"}}, "app_1?connection_ids=con_1&name_patterns=corp-%2A"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.scope.resourceId("app_1"); got != tt.id {
+			t.Errorf("resourceId() = %q, want %q", got, tt.id)
+		}
+
+		applicationId, scope, err := parseResourceId(tt.id)
+		if err != nil {
+			t.Fatalf("parseResourceId(%q): %s", tt.id, err)
+		}
+		if applicationId != "app_1" || scope.resourceId(applicationId) != tt.id {
+			t.Errorf("parseResourceId(%q) = %q, %+v", tt.id, applicationId, scope)
+		}
+	}
+}
+
+func TestParseResourceId(t *testing.T) {
+	// Import IDs may be written unescaped and in any order.
+	applicationId, scope, err := parseResourceId("app_1?name_patterns=corp-*&strategies=samlp&strategies=samlp")
+	if err != nil {
+		t.Fatalf("parseResourceId: %s", err)
+	}
+	if applicationId != "app_1" || !stringSlicesEqual(scope.Strategies, []string{"samlp"}) || !stringSlicesEqual(scope.NamePatterns, []string{"corp-*"}) {
+		t.Errorf("got %q, %+v", applicationId, scope)
+	}
+
+	for _, id := range []string{"", "?strategies=samlp", "app_1?strategy=samlp", "app_1?name_patterns=corp-[", "app_1?strategies=%zz"} {
+		if _, _, err := parseResourceId(id); err == nil {
+			t.Errorf("parseResourceId(%q) succeeded, want an error", id)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpClientSettings holds the provider settings that shape the HTTP client
+// used for every Auth0 call, including token requests.
+type httpClientSettings struct {
+	// CABundleFile is a PEM file of additional trusted root certificates,
+	// e.g. the CA of a TLS intercepting egress proxy.
+	CABundleFile string
+
+	// ProxyURL overrides the proxy taken from the HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY environment variables.
+	ProxyURL string
+
+	// ClientCertificateFile and ClientKeyFile are a PEM certificate and key
+	// presented for mutual TLS.
+	ClientCertificateFile string
+	ClientKeyFile         string
+
+	// RequestTimeout bounds a single HTTP attempt, Timeout a whole call
+	// including its retries. Zero means no limit.
+	RequestTimeout time.Duration
+	Timeout        time.Duration
+
+	RetryMaxAttempts int
+	RetryMaxWait     time.Duration
+}
+
+// newHTTPClient builds the HTTP client shared by the provider: a transport
+// configured from settings, wrapped by the retry layer.
+func newHTTPClient(settings httpClientSettings) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.ProxyURL != "" {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if settings.CABundleFile != "" {
+		pemData, err := os.ReadFile(settings.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		// Extend rather than replace the system roots, so hosts that aren't
+		// behind the intercepting proxy keep working.
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in CA bundle %s", settings.CABundleFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if settings.ClientCertificateFile != "" || settings.ClientKeyFile != "" {
+		if settings.ClientCertificateFile == "" || settings.ClientKeyFile == "" {
+			return nil, fmt.Errorf("both a client certificate and a client key are required for mutual TLS")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(settings.ClientCertificateFile, settings.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	if settings.RequestTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   settings.RequestTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = settings.RequestTimeout
+		transport.ResponseHeaderTimeout = settings.RequestTimeout
+	}
+
+	return &http.Client{
+		Transport: newRetryTransport(transport, settings.RetryMaxAttempts, settings.RetryMaxWait),
+		Timeout:   settings.Timeout,
+	}, nil
+}